@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+)
+
+type apiTeams struct {
+	Marines    int      `json:"marines"`
+	Aliens     int      `json:"aliens"`
+	Commanders []string `json:"commanders"`
+}
+
+type apiServerStatus struct {
+	Name        string     `json:"name"`
+	Address     string     `json:"address"`
+	Map         string     `json:"map"`
+	Players     int        `json:"players"`
+	PlayerNames []string   `json:"player_names"`
+	PlayerSlots int        `json:"player_slots"`
+	SpecSlots   int        `json:"spec_slots"`
+	FreeSlots   int        `json:"free_slots"`
+	TotalSlots  int        `json:"total_slots"`
+	Skill       int        `json:"skill"`
+	Up          bool       `json:"up"`
+	DownSince   *time.Time `json:"down_since,omitempty"`
+	Regulars    []string   `json:"regulars"`
+	Teams       *apiTeams  `json:"teams,omitempty"`
+}
+
+// apiStatus collects the same data the status embed and the bot's status line show
+func (srv *ns2server) apiStatus() apiServerStatus {
+	srv.lock.RLock()
+	defer srv.lock.RUnlock()
+	result := apiServerStatus{
+		Name:        srv.Name,
+		Address:     srv.Address,
+		Map:         srv.currentMap,
+		Players:     len(srv.players),
+		PlayerNames: append([]string{}, srv.players...),
+		PlayerSlots: srv.PlayerSlots,
+		SpecSlots:   srv.SpecSlots,
+		FreeSlots:   max(srv.SpecSlots+srv.PlayerSlots-len(srv.players), 0),
+		TotalSlots:  srv.SpecSlots + srv.PlayerSlots,
+		Skill:       srv.avgSkill,
+		Up:          true,
+		Regulars:    append([]string{}, srv.regularNames...),
+	}
+	if srv.failures > config.FailureLimit && srv.downSince != nil {
+		result.Up = false
+		result.DownSince = srv.downSince
+	}
+	if marines, aliens, commanders, ok := srv.teamSummary(); ok {
+		result.Teams = &apiTeams{Marines: marines, Aliens: aliens, Commanders: append([]string{}, commanders...)}
+	}
+	return result
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error writing the API response: %s", err)
+	}
+}
+
+// withCORS allows the configured origins to read the API from the browser, "*" allows everyone
+func withCORS(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if origin := r.Header.Get("Origin"); origin != "" {
+			if slices.Contains(config.HTTP.CORSOrigins, "*") {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else if slices.Contains(config.HTTP.CORSOrigins, origin) {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Add("Vary", "Origin")
+			}
+		}
+		switch r.Method {
+		case http.MethodOptions:
+			w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodGet, http.MethodHead:
+			handler(w, r)
+		default:
+			w.Header().Set("Allow", "GET, OPTIONS")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func handleAPIServers(w http.ResponseWriter, r *http.Request) {
+	result := []apiServerStatus{}
+	for _, srv := range config.Servers {
+		result = append(result, srv.apiStatus())
+	}
+	writeJSON(w, result)
+}
+
+func handleAPIServer(w http.ResponseWriter, r *http.Request) {
+	srv := serverByName(r.PathValue("name"))
+	if srv == nil {
+		http.Error(w, "unknown server", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, srv.apiStatus())
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"rkfg.me/ns2query/db"
+)
+
+func exportDB(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return db.Export(bdb, f)
+}
+
+func importDB(filename string, merge bool) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return db.Import(bdb, f, merge)
+}
+
+// sendBackup uploads the backup synchronously, the reader is drained by the first attempt so it can't go through the
+// retries of sendChan
+func sendBackup(s *discordgo.Session, user *discordgo.User) error {
+	buf := &bytes.Buffer{}
+	if err := db.Export(bdb, buf); err != nil {
+		return fmt.Errorf("error exporting database: %w", err)
+	}
+	ch, err := s.UserChannelCreate(user.ID)
+	if err != nil {
+		return fmt.Errorf("error opening DM channel: %w", err)
+	}
+	_, err = s.ChannelMessageSendComplex(ch.ID, &discordgo.MessageSend{
+		Content: "Database backup",
+		Files: []*discordgo.File{{
+			Name:        fmt.Sprintf("ns2query-%s.json", time.Now().UTC().Format("20060102-150405")),
+			ContentType: "application/json",
+			Reader:      buf,
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("error sending the backup: %w", err)
+	}
+	return nil
+}
+
+// fsckSummary counts the problems, only the fixable ones are repaired
+func fsckSummary(fixable []string, unfixable []string, repair bool) string {
+	result := fmt.Sprintf("%d problems found", len(fixable)+len(unfixable))
+	if repair {
+		result += fmt.Sprintf(", %d repaired", len(fixable))
+	}
+	if len(unfixable) > 0 {
+		result += fmt.Sprintf(", %d can't be repaired automatically", len(unfixable))
+	}
+	return result
+}
+
+func fsck(repair bool) (*discordgo.MessageSend, error) {
+	fixable, unfixable, err := db.Fsck(bdb, repair)
+	if err != nil {
+		return nil, err
+	}
+	if len(fixable)+len(unfixable) == 0 {
+		return &discordgo.MessageSend{Content: "No problems found."}, nil
+	}
+	description := ""
+	for _, p := range append(fixable, unfixable...) {
+		line := "- " + p + "\n"
+		if len(description)+len(line) > 4000 {
+			description += "..."
+			break
+		}
+		description += line
+	}
+	return &discordgo.MessageSend{Embed: &discordgo.MessageEmbed{Title: fsckSummary(fixable, unfixable, repair),
+		Description: description}}, nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	bannerWidth     = 468
+	bannerHeight    = 60
+	bannerPadding   = 6
+	bannerBarHeight = 16
+)
+
+var (
+	bannerUp   = color.RGBA{0x00, 0x99, 0x00, 0xff}
+	bannerDown = color.RGBA{0xff, 0x33, 0x00, 0xff}
+)
+
+type bannerCacheEntry struct {
+	expires time.Time
+	data    []byte
+}
+
+var bannerCache struct {
+	sync.Mutex
+	banners map[string]bannerCacheEntry
+}
+
+// currentStatus returns the variables for the status line and banner templates
+func (srv *ns2server) currentStatus() currentServerStatus {
+	srv.lock.RLock()
+	defer srv.lock.RUnlock()
+	return currentServerStatus{
+		ServerName:  srv.Name,
+		Players:     len(srv.players),
+		PlayerSlots: srv.PlayerSlots,
+		SpecSlots:   srv.SpecSlots,
+		FreeSlots:   max(srv.SpecSlots+srv.PlayerSlots-len(srv.players), 0),
+		TotalSlots:  srv.SpecSlots + srv.PlayerSlots,
+		Map:         srv.currentMap,
+		Skill:       srv.avgSkill,
+		Up:          !(srv.failures > config.FailureLimit && srv.downSince != nil),
+	}
+}
+
+// playersColor matches the embed color of the server status
+func playersColor(players int, playerSlots int) color.Color {
+	if players < config.Seeding.AlmostFull {
+		return color.RGBA{0x00, 0x99, 0x00, 0xff}
+	} else if players < playerSlots {
+		return color.RGBA{0xcc, 0x99, 0x00, 0xff}
+	}
+	return color.RGBA{0xff, 0x33, 0x00, 0xff}
+}
+
+// renderBanner draws the title (the banner template or the server name and map), the up/down badge, the players bar
+// and the average skill
+func (srv *ns2server) renderBanner() (*bytes.Buffer, error) {
+	status := srv.currentStatus()
+	title := fmt.Sprintf("%s [%s]", status.ServerName, status.Map)
+	if srv.bannerTemplate != nil {
+		buf := &bytes.Buffer{}
+		if err := srv.bannerTemplate.Execute(buf, status); err != nil {
+			log.Printf("Error executing banner template for server %s: %s", srv.Name, err)
+		} else {
+			title = buf.String()
+		}
+	}
+	img := image.NewRGBA(image.Rect(0, 0, bannerWidth, bannerHeight))
+	fillRect(img, img.Bounds(), chartBackground)
+	badgeText, badgeColor := "UP", bannerUp
+	if !status.Up {
+		badgeText, badgeColor = "DOWN", bannerDown
+	}
+	badgeWidth := textWidth("DOWN") + 10
+	badge := image.Rect(bannerWidth-bannerPadding-badgeWidth, bannerPadding, bannerWidth-bannerPadding, bannerPadding+bannerBarHeight)
+	fillRect(img, badge, badgeColor)
+	drawText(img, badge.Min.X+(badgeWidth-textWidth(badgeText))/2, badge.Max.Y-4, cardBadgeText, badgeText)
+	drawText(img, bannerPadding, badge.Max.Y-4, chartText, title)
+	barTop := bannerHeight - bannerPadding - bannerBarHeight
+	barRight := bannerWidth - bannerPadding*2 - textWidth("Skill: 9999")
+	bar := image.Rect(bannerPadding, barTop, barRight, barTop+bannerBarHeight)
+	fillRect(img, bar, cardBarBackground)
+	if status.TotalSlots > 0 {
+		width := bar.Dx() * min(status.Players, status.TotalSlots) / status.TotalSlots
+		fillRect(img, image.Rect(bar.Min.X, barTop, bar.Min.X+width, bar.Max.Y), playersColor(status.Players, status.PlayerSlots))
+	}
+	drawText(img, bar.Min.X+4, bar.Max.Y-4, chartText, fmt.Sprintf("%d/%d players", status.Players, status.TotalSlots))
+	drawText(img, barRight+bannerPadding, bar.Max.Y-4, chartText, fmt.Sprintf("Skill: %d", status.Skill))
+	result := &bytes.Buffer{}
+	if err := png.Encode(result, img); err != nil {
+		return nil, fmt.Errorf("error encoding banner: %w", err)
+	}
+	return result, nil
+}
+
+// handleBanner serves the banner of the server, it's cached for one query interval since the data doesn't change
+// more often
+func handleBanner(w http.ResponseWriter, r *http.Request) {
+	srv := serverByName(r.PathValue("server"))
+	if srv == nil {
+		http.Error(w, "unknown server", http.StatusNotFound)
+		return
+	}
+	bannerCache.Lock()
+	entry, ok := bannerCache.banners[srv.Name]
+	bannerCache.Unlock()
+	if !ok || time.Now().After(entry.expires) {
+		banner, err := srv.renderBanner()
+		if err != nil {
+			log.Printf("Error rendering banner for server %s: %s", srv.Name, err)
+			http.Error(w, "error rendering banner", http.StatusInternalServerError)
+			return
+		}
+		entry = bannerCacheEntry{expires: time.Now().Add(config.QueryInterval), data: banner.Bytes()}
+		bannerCache.Lock()
+		if bannerCache.banners == nil {
+			bannerCache.banners = map[string]bannerCacheEntry{}
+		}
+		bannerCache.banners[srv.Name] = entry
+		bannerCache.Unlock()
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(time.Until(entry.expires).Seconds())))
+	w.Write(entry.data)
+}
@@ -2,63 +2,170 @@ package main
 
 import (
 	"fmt"
+	"log"
+	"regexp"
 	"strings"
 
+	"github.com/bwmarrin/discordgo"
 	"go.etcd.io/bbolt"
 	"rkfg.me/ns2query/db"
 )
 
-func bind(player string, name string) (id uint32, err error) {
+var (
+	mentionRegex = regexp.MustCompile(`^<@!?(\d{15,21})>$`)
+)
+
+func bind(player string, user *discordgo.User) (id uint32, err error) {
 	id, err = playerIDFromSteamID(player)
 	if err != nil {
 		return
 	}
-	err = putBind(id, name)
+	err = putBind(id, user.ID, user.String())
 	return
 }
 
-func putBind(playerID uint32, name string) (err error) {
-	return bdb.Update(func(t *bbolt.Tx) (err error) {
-		err = db.NewUsersBucket(t).PutValue(name, playerID)
-		if err != nil {
-			return
-		}
-		steamBucket := db.NewSteamToDiscordBucket(t)
-		err = steamBucket.PutValue(playerID, name)
-		if err != nil {
-			return
-		}
-		return db.NewLowercaseBucket(t).PutValue(name, name)
+func putBind(playerID uint32, userID string, name string) (err error) {
+	return bdb.Update(func(t *bbolt.Tx) error {
+		return db.PutBinding(t, userID, playerID, name)
 	})
 }
 
-func getBind(username string) (playerID uint32, err error) {
+// updateBindName keeps the name index in sync for bound users that have been renamed
+func updateBindName(user *discordgo.User) {
+	name := user.String()
+	var storedName string
+	bdb.View(func(t *bbolt.Tx) (err error) {
+		storedName, err = db.NewNamesBucket(t).GetValue(user.ID)
+		return
+	})
+	if storedName == name {
+		return
+	}
+	if _, err := getBind(user.ID); err != nil {
+		return
+	}
+	err := bdb.Update(func(t *bbolt.Tx) error {
+		return db.SetBindingName(t, user.ID, name)
+	})
+	if err != nil {
+		log.Printf("Error updating name of user %s from %s to %s: %s", user.ID, storedName, name, err)
+	}
+}
+
+func getBind(userID string) (playerID uint32, err error) {
 	err = bdb.View(func(t *bbolt.Tx) (err error) {
-		playerID, err = db.NewUsersBucket(t).GetValue(username)
+		playerID, err = db.NewUsersBucket(t).GetValue(userID)
 		return
 	})
 	if err != nil {
-		return 0, fmt.Errorf("player %s isn't in the database. Use `-bind <Steam ID>` to register", username)
+		return 0, fmt.Errorf("player isn't in the database. Use `-bind <Steam ID>` to register")
 	}
 	return
 }
 
-func deleteBind(name string) (err error) {
-	return bdb.Update(func(t *bbolt.Tx) (err error) {
-		userBucket := db.NewUsersBucket(t)
-		steamBucket := db.NewSteamToDiscordBucket(t)
-		steamId, _ := userBucket.GetValue(name)
-		err = userBucket.DeleteValue(name)
-		if err != nil {
-			return
+func getBindName(t *bbolt.Tx, userID string) string {
+	name, err := db.NewNamesBucket(t).GetValue(userID)
+	if err != nil {
+		return userID
+	}
+	return name
+}
+
+// bindNameOrMention returns the stored name of the user or a mention if there's none
+func bindNameOrMention(userID string) (result string) {
+	result = "<@" + userID + ">"
+	bdb.View(func(t *bbolt.Tx) error {
+		if name, err := db.NewNamesBucket(t).GetValue(userID); err == nil {
+			result = name
 		}
-		if steamId > 0 {
-			err = steamBucket.DeleteValue(steamId)
+		return nil
+	})
+	return
+}
+
+func deleteBind(userID string) (err error) {
+	return bdb.Update(func(t *bbolt.Tx) error {
+		return db.DeleteBinding(t, userID)
+	})
+}
+
+// parseUserID accepts a user mention or a raw Discord user ID
+func parseUserID(s string) (string, error) {
+	if m := mentionRegex.FindStringSubmatch(s); m != nil {
+		return m[1], nil
+	}
+	if db.IsSnowflake(s) {
+		return s, nil
+	}
+	return "", fmt.Errorf("invalid Discord user, must be a mention or a user ID")
+}
+
+// migrateBinds rekeys the bindings made before they were stored by Discord user ID, the old user names are
+// resolved using the member lists of the guilds the bot is in. Unresolved names are kept and retried on the next start.
+func migrateBinds(s *discordgo.Session) {
+	legacy := map[string]uint32{}
+	bdb.View(func(t *bbolt.Tx) error {
+		return db.NewUsersBucket(t).ForEachValue(func(name string, playerID uint32) error {
+			if !db.IsSnowflake(name) {
+				legacy[name] = playerID
+			}
+			return nil
+		})
+	})
+	if len(legacy) == 0 {
+		return
+	}
+	log.Printf("Migrating %d bindings to Discord user IDs", len(legacy))
+	members := map[string]*discordgo.User{}
+	usernames := map[string][]*discordgo.User{}
+	for _, g := range s.State.Guilds {
+		after := ""
+		for {
+			page, err := s.GuildMembers(g.ID, after, 1000)
+			if err != nil {
+				log.Printf("Error getting members of guild %s: %s", g.ID, err)
+				break
+			}
+			for _, m := range page {
+				members[strings.ToLower(m.User.String())] = m.User
+				members[strings.ToLower(m.User.Username+"#"+m.User.Discriminator)] = m.User
+				usernames[strings.ToLower(m.User.Username)] = append(usernames[strings.ToLower(m.User.Username)], m.User)
+			}
+			if len(page) < 1000 {
+				break
+			}
+			after = page[len(page)-1].User.ID
+		}
+	}
+	migrated := 0
+	for name, playerID := range legacy {
+		user, ok := members[strings.ToLower(name)]
+		if !ok {
+			// the user could have switched from name#1234 to the new username system since binding
+			if candidates := usernames[strings.ToLower(strings.Split(name, "#")[0])]; len(candidates) == 1 {
+				user = candidates[0]
+			}
+		}
+		if user == nil {
+			log.Printf("Can't find Discord user %s bound to player ID %d, skipping", name, playerID)
+			continue
+		}
+		err := bdb.Update(func(t *bbolt.Tx) (err error) {
+			err = db.NewUsersBucket(t).DeleteValue(name)
 			if err != nil {
 				return
 			}
+			err = db.NewLowercaseBucket(t).DeleteValue(name)
+			if err != nil {
+				return
+			}
+			return db.PutBinding(t, user.ID, playerID, user.String())
+		})
+		if err != nil {
+			log.Printf("Error migrating user %s: %s", name, err)
+			continue
 		}
-		err = db.NewLowercaseBucket(t).DeleteValue(strings.ToLower(name))
-		return
-	})
+		migrated++
+	}
+	log.Printf("Migrated %d of %d bindings", migrated, len(legacy))
 }
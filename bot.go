@@ -19,11 +19,11 @@ import (
 )
 
 const (
-	cmdPrefix       = "-"
-	discordPrefix   = "!"
-	thumbsupEmoji   = "\U0001F44D"
-	clownEmoji      = "\U0001F921"
-	winkEmoji       = "\U0001F609"
+	cmdPrefix     = "-"
+	discordPrefix = "!"
+	thumbsupEmoji = "\U0001F44D"
+	clownEmoji    = "\U0001F921"
+	winkEmoji     = "\U0001F609"
 )
 
 type reaction struct {
@@ -36,6 +36,7 @@ type message struct {
 	reactionAdd    *reaction
 	reactionRemove *reaction
 	channelID      string
+	userID         string // send as a direct message to the user instead of channelID
 	retry          int
 }
 
@@ -48,60 +49,111 @@ type currentServerStatus struct {
 	FreeSlots   int
 	Map         string
 	Skill       int
+	Up          bool
 }
 
 var (
-	sendChan         = make(chan message, 10)
-	urlsChan         = make(chan msgUrls, 10)
-	discordNameRegex = regexp.MustCompile(`^.*#(?:\d{4}|0)$`)
-	urlRegex         = regexp.MustCompile(`(https?://.*)(?:\s|$)`)
+	sendChan = make(chan message, 10)
+	urlsChan = make(chan msgUrls, 10)
+	urlRegex = regexp.MustCompile(`(https?://.*)(?:\s|$)`)
+
+	errInsufficientPrivilege = fmt.Errorf("insufficient privilege")
 )
 
-func parseFields(fields []string, author *discordgo.User, channelID string) (response *discordgo.MessageSend, err error) {
+func isAdmin(user *discordgo.User) bool {
+	role, ok := config.Users[user.ID]
+	return ok && role == "admin"
+}
+
+func parseFields(s *discordgo.Session, fields []string, author *discordgo.User, channelID string) (response *discordgo.MessageSend, err error) {
 	var playerID uint32
-	switch strings.ToLower(fields[0]) {
+	command := strings.ToLower(fields[0])
+	known := true
+	defer func() {
+		// the rest is ignored by the bot and not counted to keep the number of label values bounded
+		if known {
+			commandsCounter.inc(command)
+		}
+	}()
+	switch command {
 	case "status":
 		for i := range config.Servers {
 			msg := config.Servers[i].serverStatus()
 			sendChan <- message{MessageSend: msg, channelID: channelID}
 		}
 	case "skill":
-		if len(fields) == 1 {
-			playerID, err = getBind(author.String())
-		} else {
-			if strings.HasPrefix(fields[1], discordPrefix) {
-				playerID, err = playerIDFromDiscordName(
-					strings.TrimPrefix(strings.ToLower(strings.Join(fields[1:], " ")), discordPrefix))
-			} else {
-				playerID, err = playerIDFromSteamID(fields[1])
+		if len(fields) > 1 && strings.ToLower(fields[1]) == "history" {
+			if playerID, err = playerIDFromArgs(fields[2:], author); err != nil {
+				return
+			}
+			return skillHistory(playerID)
+		}
+		full, card := false, false
+		if len(fields) > 1 {
+			switch strings.ToLower(fields[1]) {
+			case "card":
+				card = true
+				fields = fields[1:]
+			case "full":
+				full = true
+				fields = fields[1:]
+			case "compact":
+				fields = fields[1:]
 			}
 		}
-		if err != nil {
+		if playerID, err = playerIDFromArgs(fields[1:], author); err != nil {
 			return
 		}
-		if response, err = getSkill(playerID); err != nil {
+		if card {
+			return playerCard(playerID)
+		}
+		if response, err = getSkill(playerID, full); err != nil {
 			return
 		}
+	case "compare":
+		return compare(fields[1:], author)
+	case "top":
+		return top(fields[1:], author)
+	case "shuffle":
+		return shuffle(fields[1:])
+	case "pug":
+		return pug(s, fields[1:], author)
+	case "players":
+		return playersList(fields[1:])
+	case "playtime":
+		return playtime(fields[1:], author)
+	case "seeders":
+		return seeders(fields[1:])
+	case "privacy":
+		return privacy(fields[1:], author)
+	case "forgetme":
+		return forgetMe(s, fields[1:], author)
+	case "follow":
+		return follow(fields[1:], author)
+	case "unfollow":
+		return unfollow(fields[1:], author)
 	case "bind":
 		if len(fields) > 2 {
 			return nil, fmt.Errorf("invalid argument for `-bind`")
 		}
 		if len(fields) == 2 {
-			playerID, err = bind(fields[1], author.String())
+			playerID, err = bind(fields[1], author)
 			if err != nil {
 				return
 			}
+			go updatePlayerSkillRoles(s, author.ID, playerID)
 			return &discordgo.MessageSend{Content: fmt.Sprintf("User %s has been bound to player ID %d. You can use `-skill` without arguments now.",
 				author.String(), playerID)}, nil
 		}
-		err = deleteBind(author.String())
+		err = deleteBind(author.ID)
 		if err != nil {
 			return
 		}
+		go updateSkillRoles(s, author.ID, nil)
 		return &discordgo.MessageSend{Content: fmt.Sprintf("User %s has been unbound.", author.String())}, nil
 	case "bindu":
-		if role, ok := config.Users[author.ID]; !ok || role != "admin" {
-			return nil, fmt.Errorf("insufficient privilege")
+		if !isAdmin(author) {
+			return nil, errInsufficientPrivilege
 		}
 		if len(fields) > 3 {
 			return nil, fmt.Errorf("invalid arguments for `-bindu`")
@@ -109,28 +161,53 @@ func parseFields(fields []string, author *discordgo.User, channelID string) (res
 		if len(fields) < 2 {
 			return nil, fmt.Errorf("not enough arguments for `-bindu`")
 		}
-		username := fields[1]
-		if !discordNameRegex.MatchString(username) {
-			return nil, fmt.Errorf("invalid Discord name, must be in the form of `name#3333`")
+		var userID string
+		userID, err = parseUserID(fields[1])
+		if err != nil {
+			return
 		}
 		if len(fields) == 3 {
-			playerID, err = bind(fields[2], username)
+			var user *discordgo.User
+			user, err = s.User(userID)
+			if err != nil {
+				return nil, fmt.Errorf("Discord user %s not found: %w", userID, err)
+			}
+			playerID, err = bind(fields[2], user)
 			if err != nil {
 				return
 			}
+			go updatePlayerSkillRoles(s, user.ID, playerID)
 			return &discordgo.MessageSend{Content: fmt.Sprintf("User %s has been bound to player ID %d.",
-				username, playerID)}, nil
+				user.String(), playerID)}, nil
 		}
-		err = deleteBind(username)
+		name := bindNameOrMention(userID) // the name is removed with the binding
+		err = deleteBind(userID)
 		if err != nil {
 			return
 		}
-		return &discordgo.MessageSend{Content: fmt.Sprintf("User %s has been unbound.", username)}, nil
+		go updateSkillRoles(s, userID, nil)
+		return &discordgo.MessageSend{Content: fmt.Sprintf("User %s has been unbound.", name)}, nil
+	case "watch":
+		if !isAdmin(author) {
+			return nil, errInsufficientPrivilege
+		}
+		return watch(fields[1:], author)
+	case "backup":
+		if !isAdmin(author) {
+			return nil, errInsufficientPrivilege
+		}
+		return nil, sendBackup(s, author)
+	case "fsck":
+		if !isAdmin(author) {
+			return nil, errInsufficientPrivilege
+		}
+		return fsck(len(fields) > 1 && fields[1] == "repair")
 	case "version":
 		return versionEmbed(), nil
 	case "help":
 		return &discordgo.MessageSend{Embed: &discordgo.MessageEmbed{Title: "Commands",
-			Description: "Use your Steam profile page URL or its last part as a [Steam ID] argument.",
+			Description: "Use your Steam profile page URL or its last part as a [Steam ID] argument. " +
+				"`STEAM_0:1:12345`, `[U:1:24691]`, 64 bit IDs, Hive/Observatory profile links and `status` lines are understood too.",
 			Fields: []*discordgo.MessageEmbedField{
 				{
 					Name:  "-status",
@@ -139,7 +216,69 @@ func parseFields(fields []string, author *discordgo.User, channelID string) (res
 				{
 					Name: "-skill [Steam ID]",
 					Value: "show skill breakdown for player, the argument can be omitted if the player is bound. Use `!discordname` " +
-						"argument to query other registered players; no need to type the whole name, several characters should be enough.",
+						"argument to query other registered players; no need to type the whole name, several characters should be enough. " +
+						"A number is treated as a Steam account ID first and as a vanity name if there's no such account.",
+				},
+				{
+					Name:  "-skill full [Steam ID]",
+					Value: "show the skill breakdown with the player profile: NS2 hours, account age, country, Discord user and when the player was last seen on our servers.",
+				},
+				{
+					Name:  "-skill card [Steam ID]",
+					Value: "show the skill breakdown as an image with skill bars and tiers, easier to read on mobile.",
+				},
+				{
+					Name:  "-skill history [Steam ID]",
+					Value: "show a chart of the player's skill changes over the last 90 days, the argument works the same as for `-skill`.",
+				},
+				{
+					Name: "-compare <player A> <player B>",
+					Value: "compare the skills of two players, the arguments work the same as for `-skill`. " +
+						"Separate them with `vs` if they contain spaces, e.g. `-compare !some name vs STEAM_0:1:12345`.",
+				},
+				{
+					Name: "-top [skill|marine|alien|comm|td] [n]",
+					Value: "show the best n (10 by default) bound players by the chosen skill. " +
+						"Use `-top hide` to hide yourself from the leaderboard and `-top show` to be shown again.",
+				},
+				{
+					Name: "-shuffle <players>",
+					Value: "split the players into two teams with the closest skill, one commander per side is picked by comm skill. " +
+						"Players are separated by spaces and can be Discord mentions, `!names` of bound users or Steam IDs.",
+				},
+				{
+					Name: "-players [server]",
+					Value: "list the players on the server with their marine/alien skill, score and time connected. " +
+						"Only works for the servers that provide Steam IDs, the server name can be shortened.",
+				},
+				{
+					Name: "-playtime [Steam ID] [period]",
+					Value: "show how long the player has played on our servers, the player argument works the same as for `-skill`. " +
+						"The period is like `12h`, `7d` or `2w`, all time by default.",
+				},
+				{
+					Name: "-seeders [period]",
+					Value: "show the players who helped seeding our servers the most, one point per minute spent on a server " +
+						"being seeded. The period works the same as for `-playtime`.",
+				},
+				{
+					Name: "-privacy [announce|history on|off]",
+					Value: "show or change your privacy settings. With `announce off` you're not announced when joining or leaving " +
+						"the servers, with `history off` your skill history and playtime aren't recorded and you're not shown on the leaderboards.",
+				},
+				{
+					Name:  "-forgetme",
+					Value: "remove your binding and everything the bot has recorded about you, requires confirmation.",
+				},
+				{
+					Name: "-follow [player]",
+					Value: "get a direct message when the player joins our servers, without arguments lists the players you follow. " +
+						"Use `-unfollow <player>` to stop. `-follow hide` prevents others from following you, `-follow show` allows it again.",
+				},
+				{
+					Name: "-pug [join|leave|status]",
+					Value: "join or leave the pickup game queue or show who's in it. When the queue is full everyone should confirm " +
+						"they're ready, then the teams are balanced by skill. Binding is required to join.",
 				},
 				{
 					Name: "-bind [Steam ID]",
@@ -152,6 +291,8 @@ func parseFields(fields []string, author *discordgo.User, channelID string) (res
 				},
 			},
 		}}, nil
+	default:
+		known = false
 	}
 	return
 }
@@ -210,6 +351,7 @@ func handleCommand(s *discordgo.Session, m *discordgo.MessageCreate) {
 		return
 	}
 	msg := m.Message.Content
+	pugTouch(m.Author.ID)
 	if t, ok := config.Threads[m.ChannelID]; ok {
 		processThreadMessage(s, m, t)
 	}
@@ -219,7 +361,8 @@ func handleCommand(s *discordgo.Session, m *discordgo.MessageCreate) {
 	msg = strings.TrimPrefix(msg, cmdPrefix)
 	fields := strings.Fields(msg)
 	if len(fields) > 0 {
-		response, err := parseFields(fields, m.Author, m.ChannelID)
+		updateBindName(m.Author)
+		response, err := parseFields(s, fields, m.Author, m.ChannelID)
 		if err != nil {
 			response = &discordgo.MessageSend{Content: "Error: " + err.Error()}
 		}
@@ -248,6 +391,7 @@ func handleReactionRemove(s *discordgo.Session, m *discordgo.MessageReactionRemo
 }
 
 func handleReactionAdd(s *discordgo.Session, m *discordgo.MessageReactionAdd) {
+	pugReactionAdd(m)
 	msg, err := s.State.Message(m.ChannelID, m.MessageID)
 	if err != nil {
 		log.Printf("Error getting message %s from channel %s: %s", m.MessageID, m.ChannelID, err)
@@ -319,16 +463,7 @@ func statusUpdate(restartChan chan struct{}, s *discordgo.Session) {
 				if status.Len() > 0 {
 					status.WriteString(" | ")
 				}
-				cs := currentServerStatus{
-					ServerName:  s.Name,
-					Players:     len(s.players),
-					PlayerSlots: s.PlayerSlots,
-					SpecSlots:   s.SpecSlots,
-					FreeSlots:   s.SpecSlots + s.PlayerSlots - len(s.players),
-					TotalSlots:  s.SpecSlots + s.PlayerSlots,
-					Map:         s.currentMap,
-					Skill:       s.avgSkill,
-				}
+				cs := s.currentStatus()
 				if cs.Players > 0 {
 					if err := s.statusTemplate.Execute(status, cs); err != nil {
 						log.Printf("Error executing template for server %s: %s", s.Name, err)
@@ -363,16 +498,24 @@ func sendMsg(c chan message, s *discordgo.Session) {
 		if channelID == "" {
 			channelID = config.ChannelID
 		}
-		if msg.MessageSend != nil {
+		if msg.userID != "" {
+			var ch *discordgo.Channel
+			// a failure to open the DM channel is retried like any other send error
+			if ch, err = s.UserChannelCreate(msg.userID); err == nil {
+				channelID = ch.ID
+			}
+		}
+		if err == nil && msg.MessageSend != nil {
 			_, err = s.ChannelMessageSendComplex(channelID, msg.MessageSend)
 		}
-		if msg.reactionAdd != nil {
+		if err == nil && msg.reactionAdd != nil {
 			err = s.MessageReactionAdd(channelID, msg.reactionAdd.messageID, msg.reactionAdd.emojiID)
 		}
-		if msg.reactionRemove != nil {
+		if err == nil && msg.reactionRemove != nil {
 			err = s.MessageReactionRemove(channelID, msg.reactionRemove.messageID, msg.reactionRemove.emojiID, "@me")
 		}
 		if err != nil {
+			messageRetriesCounter.inc("")
 			log.Printf("Error sending message %+v: %s, retry #%d", msg, err, msg.retry+1)
 			err = nil
 			if msg.retry > 4 {
@@ -382,9 +525,11 @@ func sendMsg(c chan message, s *discordgo.Session) {
 					time.Sleep(time.Second * 5) // resend in 5 seconds
 					retryMsg := msg
 					retryMsg.retry++
-					c <- msg
+					c <- retryMsg
 				}()
 			}
+		} else {
+			messagesSentCounter.inc("")
 		}
 		time.Sleep(time.Second)
 	}
@@ -407,6 +552,7 @@ func bot() (err error) {
 		tr.TLSClientConfig = &tls.Config{ClientSessionCache: tls.NewLRUClientSessionCache(100)}
 		tr.TLSHandshakeTimeout = 2 * time.Minute
 	}
+	steamAPI = newSteamClient(config.SteamAPI, config.SteamKey)
 	var dg *discordgo.Session
 	dg, err = discordgo.New("Bot " + config.Token)
 	if err != nil {
@@ -417,6 +563,7 @@ func bot() (err error) {
 	}
 	defer dg.Close()
 	dg.State.MaxMessageCount = 1000
+	migrateBinds(dg)
 	dg.AddHandler(handleCommand)
 	dg.AddHandler(handleReactionAdd)
 	dg.AddHandler(handleReactionRemove)
@@ -438,6 +585,14 @@ func bot() (err error) {
 				config.Servers[i].statusTemplate = t
 			}
 		}
+		if config.Servers[i].BannerTemplate != "" {
+			t, err := template.New(config.Servers[i].Address + "/banner").Parse(config.Servers[i].BannerTemplate)
+			if err != nil {
+				log.Printf("Error in banner template '%s': %s", config.Servers[i].BannerTemplate, err)
+			} else {
+				config.Servers[i].bannerTemplate = t
+			}
+		}
 		if config.Servers[i].QueryIDInterval < 1 {
 			config.Servers[i].QueryIDInterval = config.QueryInterval
 		} else {
@@ -463,7 +618,47 @@ func bot() (err error) {
 			}
 		}
 	}
+	go httpServer(restartChan)
 	go statusUpdate(restartChan, dg)
+	if config.SkillRefreshInterval < 1 {
+		config.SkillRefreshInterval = time.Hour * 6
+	} else {
+		config.SkillRefreshInterval *= time.Second
+	}
+	go skillRefreshLoop(restartChan, dg)
+	if config.Pug.Size < 2 {
+		config.Pug.Size = 12
+	}
+	if config.Pug.Size > maxShufflePlayers {
+		log.Printf("PUG size %d is too big, using %d", config.Pug.Size, maxShufflePlayers)
+		config.Pug.Size = maxShufflePlayers
+	}
+	if config.Pug.IdleTimeout < 1 {
+		config.Pug.IdleTimeout = time.Hour * 2
+	} else {
+		config.Pug.IdleTimeout *= time.Second
+	}
+	if config.Pug.ReadyTimeout < 1 {
+		config.Pug.ReadyTimeout = time.Minute * 2
+	} else {
+		config.Pug.ReadyTimeout *= time.Second
+	}
+	go pugExpiryLoop(restartChan)
+	if config.Watch.Cooldown < 1 {
+		config.Watch.Cooldown = time.Hour
+	} else {
+		config.Watch.Cooldown *= time.Second
+	}
+	if config.Follow.Max < 1 {
+		config.Follow.Max = 10
+	}
+	if config.Follow.Cooldown < 1 {
+		config.Follow.Cooldown = time.Hour
+	} else {
+		config.Follow.Cooldown *= time.Second
+	}
+	go weeklyTopLoop(restartChan)
+	go seederRoleLoop(restartChan, dg)
 	startCompetitions(dg)
 	fmt.Println("Bot is now running.  Press CTRL-C to exit.")
 	sc := make(chan os.Signal, 1)
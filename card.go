@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	xdraw "golang.org/x/image/draw"
+	"rkfg.me/ns2query/db"
+)
+
+const (
+	cardWidth      = 640
+	cardHeight     = 200
+	cardPadding    = 20
+	cardAvatarSize = 160
+	cardBarHeight  = 20
+	cardBarSpacing = 10
+	cardBarScale   = 4000
+	cardTTL        = time.Minute * 5
+	maxAvatarSize  = 1 << 20
+)
+
+var (
+	cardBarBackground = color.RGBA{0x40, 0x44, 0x4b, 0xff}
+	cardBadgeText     = color.RGBA{0x20, 0x22, 0x25, 0xff}
+)
+
+type hiveTier struct {
+	name  string
+	below int
+	color color.Color
+}
+
+// hiveTiers are the skill tiers shown in game, the last one has no limit
+var hiveTiers = []hiveTier{
+	{"Recruit", 300, color.RGBA{0x99, 0x99, 0x99, 0xff}},
+	{"Frontiersman", 750, color.RGBA{0x66, 0xcc, 0x66, 0xff}},
+	{"Squad Leader", 1400, color.RGBA{0x33, 0xbb, 0xbb, 0xff}},
+	{"Veteran", 2100, color.RGBA{0x66, 0x99, 0xff, 0xff}},
+	{"Commandant", 2900, color.RGBA{0xbb, 0x77, 0xff, 0xff}},
+	{"Special Ops", 4100, color.RGBA{0xff, 0x66, 0x66, 0xff}},
+	{"Sanji Survivor", 0, color.RGBA{0xff, 0xcc, 0x33, 0xff}},
+}
+
+func tierOf(skill int) hiveTier {
+	for _, t := range hiveTiers {
+		if t.below == 0 || skill < t.below {
+			return t
+		}
+	}
+	return hiveTiers[len(hiveTiers)-1]
+}
+
+type cardCacheEntry struct {
+	expires time.Time
+	data    []byte
+}
+
+var cardCache struct {
+	sync.Mutex
+	cards map[uint32]cardCacheEntry
+}
+
+func forgetCard(playerID uint32) {
+	cardCache.Lock()
+	defer cardCache.Unlock()
+	delete(cardCache.cards, playerID)
+}
+
+func fetchAvatar(url string) (image.Image, error) {
+	c := http.Client{Timeout: 5 * time.Second}
+	resp, err := c.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error getting avatar %s: status %d", url, resp.StatusCode)
+	}
+	img, _, err := image.Decode(io.LimitReader(resp.Body, maxAvatarSize))
+	return img, err
+}
+
+// renderPlayerCard draws the avatar on the left and the field and commander skill bars with the tier badges on the
+// right, the avatar can be nil
+func renderPlayerCard(name string, avatar image.Image, skills db.Skills) (*bytes.Buffer, error) {
+	img := image.NewRGBA(image.Rect(0, 0, cardWidth, cardHeight))
+	fillRect(img, img.Bounds(), chartBackground)
+	avatarRect := image.Rect(cardPadding, cardPadding, cardPadding+cardAvatarSize, cardPadding+cardAvatarSize)
+	if avatar != nil {
+		xdraw.CatmullRom.Scale(img, avatarRect, avatar, avatar.Bounds(), xdraw.Src, nil)
+	} else {
+		fillRect(img, avatarRect, cardBarBackground)
+	}
+	left := avatarRect.Max.X + cardPadding
+	drawText(img, left, cardPadding+10, chartText, name)
+	labelWidth := textWidth("Marine comm") + 10
+	badgeWidth := textWidth("Sanji Survivor") + 10
+	barLeft := left + labelWidth
+	barRight := cardWidth - cardPadding - badgeWidth - 10
+	for i, series := range skillChartSeries {
+		value := series.value(skills)
+		top := cardPadding + 28 + i*(cardBarHeight+cardBarSpacing)
+		drawText(img, left, top+cardBarHeight/2+4, chartText, series.name)
+		fillRect(img, image.Rect(barLeft, top, barRight, top+cardBarHeight), cardBarBackground)
+		width := (barRight - barLeft) * min(max(value, 0), cardBarScale) / cardBarScale
+		fillRect(img, image.Rect(barLeft, top, barLeft+width, top+cardBarHeight), series.color)
+		label := fmt.Sprint(value)
+		if width >= textWidth(label)+8 {
+			drawText(img, barLeft+4, top+cardBarHeight/2+4, cardBadgeText, label)
+		} else {
+			drawText(img, barLeft+width+4, top+cardBarHeight/2+4, chartText, label)
+		}
+		tier := tierOf(value)
+		badge := image.Rect(barRight+10, top, barRight+10+badgeWidth, top+cardBarHeight)
+		fillRect(img, badge, tier.color)
+		drawText(img, badge.Min.X+(badgeWidth-textWidth(tier.name))/2, top+cardBarHeight/2+4, cardBadgeText, tier.name)
+	}
+	result := &bytes.Buffer{}
+	if err := png.Encode(result, img); err != nil {
+		return nil, fmt.Errorf("error encoding player card: %w", err)
+	}
+	return result, nil
+}
+
+// playerCard returns the rendered card, it's cached for a few minutes so repeated lookups don't download the avatar
+// and redraw the image every time
+func playerCard(playerID uint32) (*discordgo.MessageSend, error) {
+	cardCache.Lock()
+	entry, ok := cardCache.cards[playerID]
+	cardCache.Unlock()
+	if !ok || time.Now().After(entry.expires) {
+		skills, err := getSkills(playerID)
+		if err != nil {
+			return nil, err
+		}
+		recordSkills(playerID, skills)
+		name := "<err>"
+		var avatar image.Image
+		if summary, err := steamAPI.playerSummary(playerID); err == nil {
+			name = summary.PersonaName
+			if avatar, err = fetchAvatar(summary.LargeAvatarURL); err != nil {
+				log.Printf("Error getting avatar of player %d: %s", playerID, err)
+			}
+		}
+		card, err := renderPlayerCard(name, avatar, skills)
+		if err != nil {
+			return nil, err
+		}
+		entry = cardCacheEntry{expires: time.Now().Add(cardTTL), data: card.Bytes()}
+		cardCache.Lock()
+		if cardCache.cards == nil {
+			cardCache.cards = map[uint32]cardCacheEntry{}
+		}
+		for id, e := range cardCache.cards {
+			if time.Now().After(e.expires) {
+				delete(cardCache.cards, id)
+			}
+		}
+		cardCache.cards[playerID] = entry
+		cardCache.Unlock()
+	}
+	return &discordgo.MessageSend{
+		Files: []*discordgo.File{{Name: "card.png", ContentType: "image/png", Reader: bytes.NewReader(entry.data)}},
+	}, nil
+}
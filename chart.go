@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"time"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+	"rkfg.me/ns2query/db"
+)
+
+const (
+	chartWidth        = 800
+	chartHeight       = 400
+	chartMarginLeft   = 60
+	chartMarginRight  = 20
+	chartMarginTop    = 40
+	chartMarginBottom = 30
+	chartGridLines    = 5
+)
+
+var (
+	chartBackground = color.RGBA{0x2f, 0x31, 0x36, 0xff}
+	chartGrid       = color.RGBA{0x4f, 0x54, 0x5c, 0xff}
+	chartText       = color.RGBA{0xdc, 0xdd, 0xde, 0xff}
+	marineColor     = color.RGBA{0x33, 0x99, 0xff, 0xff}
+	marineCommColor = color.RGBA{0x99, 0xdd, 0xff, 0xff}
+	alienColor      = color.RGBA{0xff, 0x99, 0x00, 0xff}
+	alienCommColor  = color.RGBA{0xff, 0xdd, 0x66, 0xff}
+)
+
+type chartSeries struct {
+	name  string
+	color color.Color
+	value func(s db.Skills) int
+}
+
+var skillChartSeries = []chartSeries{
+	{"Marine", marineColor, func(s db.Skills) int { return s.Marine }},
+	{"Marine comm", marineCommColor, func(s db.Skills) int { return s.MarineComm }},
+	{"Alien", alienColor, func(s db.Skills) int { return s.Alien }},
+	{"Alien comm", alienCommColor, func(s db.Skills) int { return s.AlienComm }},
+}
+
+func fillRect(img draw.Image, r image.Rectangle, c color.Color) {
+	draw.Draw(img, r, image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+func drawText(img draw.Image, x int, y int, c color.Color, text string) {
+	d := font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(c),
+		Face: basicfont.Face7x13,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(text)
+}
+
+func textWidth(text string) int {
+	return font.MeasureString(basicfont.Face7x13, text).Round()
+}
+
+// drawLine draws a 2 pixel wide horizontal or vertical line
+func drawLine(img draw.Image, x1 int, y1 int, x2 int, y2 int, c color.Color) {
+	if x1 > x2 {
+		x1, x2 = x2, x1
+	}
+	if y1 > y2 {
+		y1, y2 = y2, y1
+	}
+	fillRect(img, image.Rect(x1-1, y1-1, x2+1, y2+1), c)
+}
+
+// renderSkillChart draws the skill history as a step chart, the snapshots made before the from time are drawn as
+// starting at it
+func renderSkillChart(history []db.SkillSnapshot, from time.Time, until time.Time) (*bytes.Buffer, error) {
+	img := image.NewRGBA(image.Rect(0, 0, chartWidth, chartHeight))
+	fillRect(img, img.Bounds(), chartBackground)
+	minValue, maxValue := math.MaxInt, math.MinInt
+	for _, s := range history {
+		for _, series := range skillChartSeries {
+			minValue = min(minValue, series.value(s.Skills))
+			maxValue = max(maxValue, series.value(s.Skills))
+		}
+	}
+	padding := (maxValue - minValue) / 10
+	if padding < 50 {
+		padding = 50
+	}
+	minValue -= padding
+	maxValue += padding
+	plot := image.Rect(chartMarginLeft, chartMarginTop, chartWidth-chartMarginRight, chartHeight-chartMarginBottom)
+	period := max(until.Sub(from), time.Second)
+	x := func(t time.Time) int {
+		if t.Before(from) {
+			t = from
+		}
+		return plot.Min.X + int(float64(plot.Dx())*float64(t.Sub(from))/float64(period))
+	}
+	y := func(v int) int {
+		return plot.Max.Y - int(float64(plot.Dy())*float64(v-minValue)/float64(maxValue-minValue))
+	}
+	for i := 0; i <= chartGridLines; i++ {
+		v := minValue + (maxValue-minValue)*i/chartGridLines
+		fillRect(img, image.Rect(plot.Min.X, y(v), plot.Max.X, y(v)+1), chartGrid)
+		label := fmt.Sprint(v)
+		drawText(img, plot.Min.X-textWidth(label)-6, y(v)+4, chartText, label)
+	}
+	for i, t := range []time.Time{from, from.Add(period / 2), until} {
+		label := t.Format(dateFormat)
+		lx := x(t) - textWidth(label)*i/2
+		drawText(img, lx, chartHeight-chartMarginBottom/2+4, chartText, label)
+	}
+	legendX := plot.Min.X
+	for _, series := range skillChartSeries {
+		fillRect(img, image.Rect(legendX, 14, legendX+12, 26), series.color)
+		drawText(img, legendX+18, 24, chartText, series.name)
+		legendX += textWidth(series.name) + 40
+	}
+	for _, series := range skillChartSeries {
+		for i, s := range history {
+			x1, y1 := x(s.Time), y(series.value(s.Skills))
+			x2 := x(until)
+			if i < len(history)-1 {
+				x2 = x(history[i+1].Time)
+				drawLine(img, x2, y1, x2, y(series.value(history[i+1].Skills)), series.color)
+			}
+			drawLine(img, x1, y1, x2, y1, series.color)
+		}
+	}
+	result := &bytes.Buffer{}
+	if err := png.Encode(result, img); err != nil {
+		return nil, fmt.Errorf("error encoding chart: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// splitCompareArgs splits the arguments into two players, either by the "vs" word (to allow names with spaces) or
+// as exactly two words
+func splitCompareArgs(args []string) ([]string, []string, error) {
+	for i, a := range args {
+		if strings.ToLower(a) == "vs" {
+			if i == 0 || i == len(args)-1 {
+				break
+			}
+			return args[:i], args[i+1:], nil
+		}
+	}
+	if len(args) != 2 {
+		return nil, nil, fmt.Errorf("specify two players to compare, separate them with `vs` if they contain spaces")
+	}
+	return args[:1], args[1:], nil
+}
+
+func formatCompared(value int, other int) string {
+	if value > other {
+		return fmt.Sprintf("**%d**", value)
+	}
+	return fmt.Sprint(value)
+}
+
+func compare(args []string, author *discordgo.User) (*discordgo.MessageSend, error) {
+	argsA, argsB, err := splitCompareArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	playerA, err := playerIDFromArgs(argsA, author)
+	if err != nil {
+		return nil, err
+	}
+	playerB, err := playerIDFromArgs(argsB, author)
+	if err != nil {
+		return nil, err
+	}
+	skillsA, err := getSkills(playerA)
+	if err != nil {
+		return nil, err
+	}
+	recordSkills(playerA, skillsA)
+	skillsB, err := getSkills(playerB)
+	if err != nil {
+		return nil, err
+	}
+	recordSkills(playerB, skillsB)
+	nameA, _ := playerNameAvatar(playerA)
+	nameB, _ := playerNameAvatar(playerB)
+	msg := &discordgo.MessageSend{Embed: &discordgo.MessageEmbed{
+		Title: fmt.Sprintf("%s vs %s", nameA, nameB),
+	}}
+	winsA, winsB := 0, 0
+	for _, f := range skillFields {
+		a, b := f.value(skillsA), f.value(skillsB)
+		if a > b {
+			winsA++
+		} else if b > a {
+			winsB++
+		}
+		msg.Embed.Fields = append(msg.Embed.Fields, &discordgo.MessageEmbedField{
+			Name:   f.name,
+			Value:  fmt.Sprintf("%s : %s (%+d)", formatCompared(a, b), formatCompared(b, a), a-b),
+			Inline: true,
+		})
+	}
+	msg.Embed.Footer = &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("%s %d : %d %s", nameA, winsA, winsB, nameB)}
+	return msg, nil
+}
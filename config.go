@@ -4,8 +4,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sync"
 	"text/template"
 	"time"
+
+	"github.com/rumblefrog/go-a2s"
 )
 
 type state int
@@ -29,11 +32,14 @@ type ns2server struct {
 	SpecSlots            int           `json:"spec_slots"`
 	PlayerSlots          int           `json:"player_slots"`
 	StatusTemplate       string        `json:"status_template"`
+	BannerTemplate       string        `json:"banner_template"`
 	IDURL                string        `json:"id_url"`
 	QueryIDInterval      time.Duration `json:"query_id_interval"`
 	AnnounceDelay        time.Duration `json:"announce_delay"`
 	RegularTimeout       time.Duration `json:"regular_timeout"`
 	RegularChannelID     string        `json:"regular_channel_id"`
+	AnnounceDepartures   bool          `json:"announce_departures"`
+	Push                 bool          `json:"push"`
 	DownNotifyDiscordIDs []string      `json:"down_notify_ids"`
 	UpNotifyDiscordIDs   []string      `json:"up_notify_ids"`
 	regularTimeouts      map[uint32]*time.Time
@@ -41,17 +47,30 @@ type ns2server struct {
 	newRegulars          map[uint32]regular
 	announceScheduled    bool
 	statusTemplate       *template.Template
+	bannerTemplate       *template.Template
 	players              []string
+	playerDetails        []*a2s.Player
+	playerList           []playerInfo
+	pushChan             chan []playerInfo
 	serverState          state
 	maxStateToMessage    state
 	lastStateAnnounced   state
 	lastStatePromotion   time.Time
+	lastSeedCredit       time.Time
+	playersState         state
+	seedingRound         bool
+	untrackedIDs         map[uint32]struct{}
 	currentMap           string
 	avgSkill             int
 	restartChan          chan struct{}
 	failures             int
 	downSince            *time.Time
 	sessionStart         *time.Time
+	queryDuration        time.Duration
+	// lock guards the status fields the query loops write and the command handlers, the other loop and the HTTP
+	// handlers read: currentMap, avgSkill, players, playerDetails, playerList, regularNames, failures, downSince,
+	// queryDuration and seedingRound
+	lock sync.RWMutex
 }
 
 func (s *ns2server) playersString() string {
@@ -122,6 +141,7 @@ type users map[string]string
 var config struct {
 	Token         string            `json:"token"`
 	SteamKey      string            `json:"steam_key"`
+	SteamAPI      steamAPIConfig    `json:"steam_api"`
 	ChannelID     string            `json:"channel_id"`
 	Threads       map[string]thread `json:"threads"`
 	BoltDBPath    string            `json:"bdb_database_path"`
@@ -131,6 +151,15 @@ var config struct {
 	Servers       []*ns2server      `json:"servers"`
 	Seeding       seeding           `json:"seeding"`
 	Users         users             `json:"users"`
+
+	SkillRefreshInterval time.Duration    `json:"skill_refresh_interval"`
+	Pug                  pugConfig        `json:"pug"`
+	SkillRoles           skillRolesConfig `json:"skill_roles"`
+	PlaytimeChannelID    string           `json:"playtime_channel_id"`
+	HTTP                 httpConfig       `json:"http"`
+	Follow               followConfig     `json:"follow"`
+	Watch                watchConfig      `json:"watch"`
+	Seeders              seedersConfig    `json:"seeders"`
 }
 
 func loadConfigFilename(filename string) error {
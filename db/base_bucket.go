@@ -38,3 +38,17 @@ func (b Bucket[Key, Value]) FindFirstValue(prefix Key) (result Value, err error)
 	}
 	return b.valueConverter.convertFrom(v), nil
 }
+
+func (b Bucket[Key, Value]) ForEachValue(f func(key Key, value Value) error) error {
+	return b.ForEach(func(k, v []byte) error {
+		return f(b.keyConverter.convertFrom(k), b.valueConverter.convertFrom(v))
+	})
+}
+
+func (b Bucket[Key, Value]) Count() (result int) {
+	c := b.Cursor()
+	for k, _ := c.First(); k != nil; k, _ = c.Next() {
+		result++
+	}
+	return
+}
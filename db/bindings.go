@@ -0,0 +1,88 @@
+package db
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.etcd.io/bbolt"
+)
+
+var (
+	ErrSteamIDBound = fmt.Errorf("this Steam ID is already bound to another user")
+
+	snowflakeRegex = regexp.MustCompile(`^\d{15,21}$`)
+)
+
+// IsSnowflake reports whether the string is a Discord user ID. The bindings made before they were keyed by user ID
+// use the Discord user names, the bot migrates them on start and keeps those it can't resolve for the next time.
+func IsSnowflake(s string) bool {
+	return snowflakeRegex.MatchString(s)
+}
+
+// PutBinding binds the Discord user to the Steam ID and updates the reverse and name indexes. The previous Steam ID of
+// the user is unindexed. Binding a Steam ID that belongs to another user returns ErrSteamIDBound.
+func PutBinding(tx *bbolt.Tx, userID string, steamID uint32, name string) error {
+	users := NewUsersBucket(tx)
+	steam := NewSteamToDiscordBucket(tx)
+	if owner, err := steam.GetValue(steamID); err == nil && owner != userID {
+		if ownerSteamID, err := users.GetValue(owner); err == nil && ownerSteamID == steamID {
+			return ErrSteamIDBound
+		}
+	}
+	if oldSteamID, err := users.GetValue(userID); err == nil && oldSteamID != steamID {
+		if err = steam.DeleteValue(oldSteamID); err != nil {
+			return err
+		}
+	}
+	if err := users.PutValue(userID, steamID); err != nil {
+		return err
+	}
+	if err := steam.PutValue(steamID, userID); err != nil {
+		return err
+	}
+	return SetBindingName(tx, userID, name)
+}
+
+// SetBindingName updates the name index of the Discord user
+func SetBindingName(tx *bbolt.Tx, userID string, name string) error {
+	names := NewNamesBucket(tx)
+	lowercase := NewLowercaseBucket(tx)
+	if oldName, err := names.GetValue(userID); err == nil && !strings.EqualFold(oldName, name) {
+		if owner, _ := lowercase.GetValue(oldName); owner == userID {
+			if err = lowercase.DeleteValue(oldName); err != nil {
+				return err
+			}
+		}
+	}
+	if err := names.PutValue(userID, name); err != nil {
+		return err
+	}
+	return lowercase.PutValue(name, userID)
+}
+
+// DeleteBinding removes the Discord user binding and all the index entries pointing to it
+func DeleteBinding(tx *bbolt.Tx, userID string) error {
+	users := NewUsersBucket(tx)
+	steam := NewSteamToDiscordBucket(tx)
+	names := NewNamesBucket(tx)
+	lowercase := NewLowercaseBucket(tx)
+	if steamID, err := users.GetValue(userID); err == nil {
+		if owner, _ := steam.GetValue(steamID); owner == userID {
+			if err = steam.DeleteValue(steamID); err != nil {
+				return err
+			}
+		}
+	}
+	if err := users.DeleteValue(userID); err != nil {
+		return err
+	}
+	if name, err := names.GetValue(userID); err == nil {
+		if owner, _ := lowercase.GetValue(name); owner == userID {
+			if err = lowercase.DeleteValue(name); err != nil {
+				return err
+			}
+		}
+	}
+	return names.DeleteValue(userID)
+}
@@ -9,28 +9,6 @@ func OpenBoltDB(dbPath string) (bdb *bbolt.DB, err error) {
 	return
 }
 
-func InitBoltDB(bdb *bbolt.DB) {
-	err := bdb.Update(func(t *bbolt.Tx) error {
-		_, err := t.CreateBucketIfNotExists(discordBucketName)
-		if err != nil {
-			return err
-		}
-		_, err = t.CreateBucketIfNotExists(steamidBucketName)
-		if err != nil {
-			return err
-		}
-		_, err = t.CreateBucketIfNotExists(lowercaseBucketName)
-		if err != nil {
-			return err
-		}
-		_, err = t.CreateBucketIfNotExists(memesBucketName)
-		return err
-	})
-	if err != nil {
-		panic(err)
-	}
-}
-
 func CloseBoltDB(bdb *bbolt.DB) error {
 	return bdb.Close()
 }
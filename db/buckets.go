@@ -2,16 +2,24 @@ package db
 
 import (
 	"fmt"
+	"time"
 
 	"go.etcd.io/bbolt"
 )
 
 var (
-	discordBucketName   = []byte("discord_to_steamid")
-	steamidBucketName   = []byte("steamid_to_discord")
-	lowercaseBucketName = []byte("lowercase_to_normalcase")
-	memesBucketName     = []byte("memes")
-	ErrNotFound         = fmt.Errorf("not found")
+	discordBucketName      = []byte("discord_to_steamid")
+	steamidBucketName      = []byte("steamid_to_discord")
+	lowercaseBucketName    = []byte("lowercase_to_normalcase")
+	namesBucketName        = []byte("discordid_to_name")
+	steamCacheBucketName   = []byte("steam_cache")
+	userSettingsBucketName = []byte("user_settings")
+	pugQueueBucketName     = []byte("pug_queue")
+	lastSeenBucketName     = []byte("last_seen")
+	followsBucketName      = []byte("follows")
+	watchlistBucketName    = []byte("watchlist")
+	memesBucketName        = []byte("memes")
+	ErrNotFound            = fmt.Errorf("not found")
 )
 
 type UsersBucket struct {
@@ -40,6 +48,19 @@ func NewLowercaseBucket(tx *bbolt.Tx) LowercaseBucket {
 		}}
 }
 
+type NamesBucket struct {
+	Bucket[string, string]
+}
+
+func NewNamesBucket(tx *bbolt.Tx) NamesBucket {
+	return NamesBucket{
+		Bucket[string, string]{
+			tx.Bucket(namesBucketName),
+			StringConverter{},
+			StringConverter{},
+		}}
+}
+
 type SteamToDiscordBucket struct {
 	Bucket[uint32, string]
 }
@@ -54,8 +75,8 @@ func NewSteamToDiscordBucket(tx *bbolt.Tx) SteamToDiscordBucket {
 }
 
 type MemeStatus struct {
-	LastAnnouncementDay int
-	LastWinnerID        string
+	LastAnnouncementDay int    `json:"last_announcement_day"`
+	LastWinnerID        string `json:"last_winner_id"`
 }
 type MemesBucket struct {
 	Bucket[string, MemeStatus]
@@ -68,3 +89,124 @@ func NewMemesBucket(tx *bbolt.Tx) MemesBucket {
 		StructConverter[MemeStatus]{},
 	}}
 }
+
+type CacheEntry struct {
+	Expires time.Time
+	Data    []byte
+}
+
+type SteamCacheBucket struct {
+	Bucket[string, CacheEntry]
+}
+
+func NewSteamCacheBucket(tx *bbolt.Tx) SteamCacheBucket {
+	return SteamCacheBucket{Bucket[string, CacheEntry]{
+		tx.Bucket(steamCacheBucketName),
+		StringConverter{},
+		StructConverter[CacheEntry]{},
+	}}
+}
+
+// PruneExpired deletes the entries that expired before now and returns their number
+func (b SteamCacheBucket) PruneExpired(now time.Time) (int, error) {
+	expired := []string{}
+	err := b.ForEachValue(func(key string, entry CacheEntry) error {
+		if entry.Expires.Before(now) {
+			expired = append(expired, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, key := range expired {
+		if err := b.DeleteValue(key); err != nil {
+			return 0, err
+		}
+	}
+	return len(expired), nil
+}
+
+type UserSettings struct {
+	HideFromLeaderboard bool `json:"hide_from_leaderboard"`
+	HideFromFollow      bool `json:"hide_from_follow"`
+	NoAnnounce          bool `json:"no_announce"`
+	NoHistory           bool `json:"no_history"`
+}
+
+type UserSettingsBucket struct {
+	Bucket[string, UserSettings]
+}
+
+func NewUserSettingsBucket(tx *bbolt.Tx) UserSettingsBucket {
+	return UserSettingsBucket{Bucket[string, UserSettings]{
+		tx.Bucket(userSettingsBucketName),
+		StringConverter{},
+		StructConverter[UserSettings]{},
+	}}
+}
+
+type PugEntry struct {
+	Joined     time.Time `json:"joined"`
+	LastActive time.Time `json:"last_active"`
+}
+
+type PugQueueBucket struct {
+	Bucket[string, PugEntry]
+}
+
+func NewPugQueueBucket(tx *bbolt.Tx) PugQueueBucket {
+	return PugQueueBucket{Bucket[string, PugEntry]{
+		tx.Bucket(pugQueueBucketName),
+		StringConverter{},
+		StructConverter[PugEntry]{},
+	}}
+}
+
+type LastSeen struct {
+	Time   time.Time `json:"time"`
+	Server string    `json:"server"`
+}
+
+type LastSeenBucket struct {
+	Bucket[uint32, LastSeen]
+}
+
+func NewLastSeenBucket(tx *bbolt.Tx) LastSeenBucket {
+	return LastSeenBucket{Bucket[uint32, LastSeen]{
+		tx.Bucket(lastSeenBucketName),
+		U32Converter{},
+		StructConverter[LastSeen]{},
+	}}
+}
+
+// FollowsBucket stores the Steam IDs each Discord user wants to be notified about
+type FollowsBucket struct {
+	Bucket[string, []uint32]
+}
+
+func NewFollowsBucket(tx *bbolt.Tx) FollowsBucket {
+	return FollowsBucket{Bucket[string, []uint32]{
+		tx.Bucket(followsBucketName),
+		StringConverter{},
+		StructConverter[[]uint32]{},
+	}}
+}
+
+type WatchEntry struct {
+	Reason  string    `json:"reason"`
+	AddedBy string    `json:"added_by"`
+	Added   time.Time `json:"added"`
+}
+
+type WatchlistBucket struct {
+	Bucket[uint32, WatchEntry]
+}
+
+func NewWatchlistBucket(tx *bbolt.Tx) WatchlistBucket {
+	return WatchlistBucket{Bucket[uint32, WatchEntry]{
+		tx.Bucket(watchlistBucketName),
+		U32Converter{},
+		StructConverter[WatchEntry]{},
+	}}
+}
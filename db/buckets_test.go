@@ -0,0 +1,34 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+func TestPruneSteamCache(t *testing.T) {
+	bdb := openTestDB(t)
+	Migrate(bdb, false)
+	now := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	err := bdb.Update(func(tx *bbolt.Tx) error {
+		cache := NewSteamCacheBucket(tx)
+		cache.PutValue("vanity:old", CacheEntry{Expires: now.Add(-time.Hour), Data: []byte("1")})
+		cache.PutValue("summary:1", CacheEntry{Expires: now.Add(-time.Second), Data: []byte("{}")})
+		cache.PutValue("stats:1", CacheEntry{Expires: now.Add(time.Hour), Data: []byte("{}")})
+		pruned, err := cache.PruneExpired(now)
+		if pruned != 2 {
+			t.Errorf("expected 2 pruned entries, got %d", pruned)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bdb.View(func(tx *bbolt.Tx) error {
+		if count := NewSteamCacheBucket(tx).Count(); count != 1 {
+			t.Errorf("expected 1 entry left, got %d", count)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,369 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"strings"
+
+	"go.etcd.io/bbolt"
+)
+
+// Dump is the JSON representation of the whole database, every field is named after the bucket it's stored in
+type Dump struct {
+	SchemaVersion  uint32                     `json:"schema_version"`
+	Users          map[string]uint32          `json:"discord_to_steamid"`
+	SteamToDiscord map[uint32]string          `json:"steamid_to_discord"`
+	Lowercase      map[string]string          `json:"lowercase_to_normalcase"`
+	Names          map[string]string          `json:"discordid_to_name"`
+	Memes          map[string]MemeStatus      `json:"memes"`
+	SkillHistory   map[uint32][]SkillSnapshot `json:"skill_history"`
+	UserSettings   map[string]UserSettings    `json:"user_settings"`
+	PugQueue       map[string]PugEntry        `json:"pug_queue"`
+	LastSeen       map[uint32]LastSeen        `json:"last_seen"`
+	Sessions       map[uint32][]SessionRecord `json:"sessions"`
+	OpenSessions   map[uint32]OpenSession     `json:"open_sessions"`
+	Playtime       map[uint32]Playtime        `json:"playtime"`
+	Follows        map[string][]uint32        `json:"follows"`
+	Watchlist      map[uint32]WatchEntry      `json:"watchlist"`
+	Seeding        map[uint32][]SeedingDay    `json:"seeding"`
+}
+
+func newDump() *Dump {
+	return &Dump{
+		SchemaVersion:  SchemaVersion(),
+		Users:          map[string]uint32{},
+		SteamToDiscord: map[uint32]string{},
+		Lowercase:      map[string]string{},
+		Names:          map[string]string{},
+		Memes:          map[string]MemeStatus{},
+		SkillHistory:   map[uint32][]SkillSnapshot{},
+		UserSettings:   map[string]UserSettings{},
+		PugQueue:       map[string]PugEntry{},
+		LastSeen:       map[uint32]LastSeen{},
+		Sessions:       map[uint32][]SessionRecord{},
+		OpenSessions:   map[uint32]OpenSession{},
+		Playtime:       map[uint32]Playtime{},
+		Follows:        map[string][]uint32{},
+		Watchlist:      map[uint32]WatchEntry{},
+		Seeding:        map[uint32][]SeedingDay{},
+	}
+}
+
+func readDump(tx *bbolt.Tx) (*Dump, error) {
+	result := newDump()
+	err := NewUsersBucket(tx).ForEachValue(func(k string, v uint32) error {
+		result.Users[k] = v
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = NewSteamToDiscordBucket(tx).ForEachValue(func(k uint32, v string) error {
+		result.SteamToDiscord[k] = v
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = NewLowercaseBucket(tx).ForEachValue(func(k string, v string) error {
+		result.Lowercase[k] = v
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = NewNamesBucket(tx).ForEachValue(func(k string, v string) error {
+		result.Names[k] = v
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = NewMemesBucket(tx).ForEachValue(func(k string, v MemeStatus) error {
+		result.Memes[k] = v
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = NewSkillHistoryBucket(tx).ForEachValue(func(k HistoryKey, v Skills) error {
+		result.SkillHistory[k.PlayerID] = append(result.SkillHistory[k.PlayerID], SkillSnapshot{Time: k.Time, Skills: v})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = NewUserSettingsBucket(tx).ForEachValue(func(k string, v UserSettings) error {
+		result.UserSettings[k] = v
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = NewPugQueueBucket(tx).ForEachValue(func(k string, v PugEntry) error {
+		result.PugQueue[k] = v
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = NewLastSeenBucket(tx).ForEachValue(func(k uint32, v LastSeen) error {
+		result.LastSeen[k] = v
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = NewSessionsBucket(tx).ForEachValue(func(k HistoryKey, v Session) error {
+		result.Sessions[k.PlayerID] = append(result.Sessions[k.PlayerID], SessionRecord{Start: k.Time, Session: v})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = NewOpenSessionsBucket(tx).ForEachValue(func(k uint32, v OpenSession) error {
+		result.OpenSessions[k] = v
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = NewPlaytimeBucket(tx).ForEachValue(func(k uint32, v Playtime) error {
+		result.Playtime[k] = v
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = NewFollowsBucket(tx).ForEachValue(func(k string, v []uint32) error {
+		result.Follows[k] = v
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = NewWatchlistBucket(tx).ForEachValue(func(k uint32, v WatchEntry) error {
+		result.Watchlist[k] = v
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = NewSeedingBucket(tx).ForEachValue(func(k HistoryKey, v uint32) error {
+		result.Seeding[k.PlayerID] = append(result.Seeding[k.PlayerID], SeedingDay{Day: k.Time, Seconds: v})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Export writes the database contents as JSON, the data is read in a single transaction so it's consistent
+func Export(bdb *bbolt.DB, w io.Writer) error {
+	return bdb.View(func(tx *bbolt.Tx) error {
+		dump, err := readDump(tx)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(dump)
+	})
+}
+
+// isLegacyLowercase reports whether the lowercase index entry belongs to a legacy binding, those are indexed by the
+// lowercased user name without a separate name entry
+func (d *Dump) isLegacyLowercase(lowercase string, userID string) bool {
+	_, bound := d.Users[userID]
+	return bound && !IsSnowflake(userID) && strings.ToLower(userID) == lowercase
+}
+
+// Validate checks that the binding buckets reference each other correctly
+func (d *Dump) Validate() error {
+	for userID, steamID := range d.Users {
+		if d.SteamToDiscord[steamID] != userID {
+			return fmt.Errorf("user %s is bound to Steam ID %d but it's indexed to user '%s'", userID, steamID, d.SteamToDiscord[steamID])
+		}
+	}
+	for steamID, userID := range d.SteamToDiscord {
+		if boundID, ok := d.Users[userID]; !ok || boundID != steamID {
+			return fmt.Errorf("Steam ID %d is indexed to user %s who isn't bound to it", steamID, userID)
+		}
+	}
+	for userID, name := range d.Names {
+		if _, ok := d.Users[userID]; !ok {
+			return fmt.Errorf("name '%s' belongs to user %s who isn't bound", name, userID)
+		}
+		if d.Lowercase[strings.ToLower(name)] != userID {
+			return fmt.Errorf("name '%s' of user %s isn't in the lowercase index", name, userID)
+		}
+	}
+	for lowercase, userID := range d.Lowercase {
+		if lowercase != strings.ToLower(d.Names[userID]) && !d.isLegacyLowercase(lowercase, userID) {
+			return fmt.Errorf("lowercase name '%s' doesn't match the name of user %s", lowercase, userID)
+		}
+	}
+	return nil
+}
+
+func recreateBucket(tx *bbolt.Tx, name []byte) error {
+	if tx.Bucket(name) != nil {
+		if err := tx.DeleteBucket(name); err != nil {
+			return err
+		}
+	}
+	_, err := tx.CreateBucket(name)
+	return err
+}
+
+func writeDump(tx *bbolt.Tx, d *Dump) error {
+	for _, name := range [][]byte{discordBucketName, steamidBucketName, lowercaseBucketName, namesBucketName, memesBucketName,
+		skillHistoryBucketName, userSettingsBucketName, pugQueueBucketName, lastSeenBucketName,
+		sessionsBucketName, openSessionsBucketName, playtimeBucketName, followsBucketName, watchlistBucketName, seedingBucketName} {
+		if err := recreateBucket(tx, name); err != nil {
+			return err
+		}
+	}
+	users := NewUsersBucket(tx)
+	for k, v := range d.Users {
+		if err := users.PutValue(k, v); err != nil {
+			return err
+		}
+	}
+	steamToDiscord := NewSteamToDiscordBucket(tx)
+	for k, v := range d.SteamToDiscord {
+		if err := steamToDiscord.PutValue(k, v); err != nil {
+			return err
+		}
+	}
+	lowercase := NewLowercaseBucket(tx)
+	for k, v := range d.Lowercase {
+		if err := lowercase.PutValue(k, v); err != nil {
+			return err
+		}
+	}
+	names := NewNamesBucket(tx)
+	for k, v := range d.Names {
+		if err := names.PutValue(k, v); err != nil {
+			return err
+		}
+	}
+	memes := NewMemesBucket(tx)
+	for k, v := range d.Memes {
+		if err := memes.PutValue(k, v); err != nil {
+			return err
+		}
+	}
+	history := NewSkillHistoryBucket(tx)
+	for playerID, snapshots := range d.SkillHistory {
+		for _, s := range snapshots {
+			if err := history.PutValue(HistoryKey{PlayerID: playerID, Time: s.Time}, s.Skills); err != nil {
+				return err
+			}
+		}
+	}
+	settings := NewUserSettingsBucket(tx)
+	for k, v := range d.UserSettings {
+		if err := settings.PutValue(k, v); err != nil {
+			return err
+		}
+	}
+	pugQueue := NewPugQueueBucket(tx)
+	for k, v := range d.PugQueue {
+		if err := pugQueue.PutValue(k, v); err != nil {
+			return err
+		}
+	}
+	lastSeen := NewLastSeenBucket(tx)
+	for k, v := range d.LastSeen {
+		if err := lastSeen.PutValue(k, v); err != nil {
+			return err
+		}
+	}
+	sessions := NewSessionsBucket(tx)
+	for playerID, records := range d.Sessions {
+		for _, r := range records {
+			if err := sessions.PutValue(HistoryKey{PlayerID: playerID, Time: r.Start}, r.Session); err != nil {
+				return err
+			}
+		}
+	}
+	openSessions := NewOpenSessionsBucket(tx)
+	for k, v := range d.OpenSessions {
+		if err := openSessions.PutValue(k, v); err != nil {
+			return err
+		}
+	}
+	playtime := NewPlaytimeBucket(tx)
+	for k, v := range d.Playtime {
+		if err := playtime.PutValue(k, v); err != nil {
+			return err
+		}
+	}
+	follows := NewFollowsBucket(tx)
+	for k, v := range d.Follows {
+		if err := follows.PutValue(k, v); err != nil {
+			return err
+		}
+	}
+	watchlist := NewWatchlistBucket(tx)
+	for k, v := range d.Watchlist {
+		if err := watchlist.PutValue(k, v); err != nil {
+			return err
+		}
+	}
+	seeding := NewSeedingBucket(tx)
+	for playerID, days := range d.Seeding {
+		for _, s := range days {
+			if err := seeding.PutValue(HistoryKey{PlayerID: playerID, Time: s.Day}, s.Seconds); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Import replaces the database contents with the JSON dump. If merge is set the existing data is kept and only
+// overwritten by the entries present in the dump. The resulting data is validated before anything is written.
+func Import(bdb *bbolt.DB, r io.Reader, merge bool) error {
+	dump := newDump()
+	dump.SchemaVersion = 0
+	if err := json.NewDecoder(r).Decode(dump); err != nil {
+		return fmt.Errorf("error decoding dump: %w", err)
+	}
+	if dump.SchemaVersion != SchemaVersion() {
+		return fmt.Errorf("dump schema version %d doesn't match the database schema version %d", dump.SchemaVersion, SchemaVersion())
+	}
+	return bdb.Update(func(tx *bbolt.Tx) error {
+		result := dump
+		if merge {
+			current, err := readDump(tx)
+			if err != nil {
+				return err
+			}
+			maps.Copy(current.Users, dump.Users)
+			maps.Copy(current.SteamToDiscord, dump.SteamToDiscord)
+			maps.Copy(current.Lowercase, dump.Lowercase)
+			maps.Copy(current.Names, dump.Names)
+			maps.Copy(current.Memes, dump.Memes)
+			maps.Copy(current.SkillHistory, dump.SkillHistory)
+			maps.Copy(current.UserSettings, dump.UserSettings)
+			maps.Copy(current.PugQueue, dump.PugQueue)
+			maps.Copy(current.LastSeen, dump.LastSeen)
+			maps.Copy(current.Sessions, dump.Sessions)
+			maps.Copy(current.OpenSessions, dump.OpenSessions)
+			maps.Copy(current.Playtime, dump.Playtime)
+			maps.Copy(current.Follows, dump.Follows)
+			maps.Copy(current.Watchlist, dump.Watchlist)
+			maps.Copy(current.Seeding, dump.Seeding)
+			result = current
+		}
+		if err := result.Validate(); err != nil {
+			return fmt.Errorf("inconsistent data: %w", err)
+		}
+		return writeDump(tx, result)
+	})
+}
@@ -0,0 +1,76 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"go.etcd.io/bbolt"
+)
+
+func bindTestUser(t *testing.T, bdb *bbolt.DB, userID string, steamID uint32, name string) {
+	err := bdb.Update(func(tx *bbolt.Tx) error {
+		NewUsersBucket(tx).PutValue(userID, steamID)
+		NewSteamToDiscordBucket(tx).PutValue(steamID, userID)
+		NewNamesBucket(tx).PutValue(userID, name)
+		return NewLowercaseBucket(tx).PutValue(name, userID)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	src := openTestDB(t)
+	Migrate(src, false)
+	bindTestUser(t, src, "100000000000000001", 1, "Alice")
+	bindTestUser(t, src, "100000000000000002", 2, "Bob")
+	buf := &bytes.Buffer{}
+	if err := Export(src, buf); err != nil {
+		t.Fatal(err)
+	}
+	dst := openTestDB(t)
+	Migrate(dst, false)
+	bindTestUser(t, dst, "100000000000000003", 3, "Carol")
+	if err := Import(dst, bytes.NewReader(buf.Bytes()), true); err != nil {
+		t.Fatal(err)
+	}
+	dst.View(func(tx *bbolt.Tx) error {
+		for _, id := range []uint32{1, 2, 3} {
+			if _, err := NewSteamToDiscordBucket(tx).GetValue(id); err != nil {
+				t.Errorf("Steam ID %d not found after merge", id)
+			}
+		}
+		if userID, _ := NewLowercaseBucket(tx).GetValue("bob"); userID != "100000000000000002" {
+			t.Errorf("expected Bob's ID in the lowercase index, got '%s'", userID)
+		}
+		return nil
+	})
+	if err := Import(dst, bytes.NewReader(buf.Bytes()), false); err != nil {
+		t.Fatal(err)
+	}
+	dst.View(func(tx *bbolt.Tx) error {
+		if _, err := NewUsersBucket(tx).GetValue("100000000000000003"); err != ErrNotFound {
+			t.Errorf("user wasn't removed by a full import")
+		}
+		return nil
+	})
+}
+
+func TestImportInconsistent(t *testing.T) {
+	bdb := openTestDB(t)
+	Migrate(bdb, false)
+	bindTestUser(t, bdb, "100000000000000001", 1, "Alice")
+	dump := fmt.Sprintf(`{"schema_version": %d, "discord_to_steamid": {"100000000000000002": 1}, `+
+		`"steamid_to_discord": {"1": "100000000000000002"}}`, SchemaVersion())
+	if err := Import(bdb, strings.NewReader(dump), true); err == nil {
+		t.Errorf("expected a consistency error")
+	}
+	bdb.View(func(tx *bbolt.Tx) error {
+		if userID, _ := NewSteamToDiscordBucket(tx).GetValue(1); userID != "100000000000000001" {
+			t.Errorf("failed import modified the database")
+		}
+		return nil
+	})
+}
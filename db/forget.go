@@ -0,0 +1,76 @@
+package db
+
+import (
+	"bytes"
+	"slices"
+
+	"go.etcd.io/bbolt"
+)
+
+func deletePrefix(b *bbolt.Bucket, prefix []byte) error {
+	c := b.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Seek(prefix) {
+		if err := c.Delete(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ForgetUser removes every record tied to the Discord user and the Steam ID bound to them: the binding, settings,
+// follows, the PUG queue entry, the skill history, sessions, playtime and seeding time. The Steam ID is also removed
+// from the follow lists of other users. It returns the Steam ID the user was bound to or 0.
+func ForgetUser(tx *bbolt.Tx, userID string) (uint32, error) {
+	steamID, _ := NewUsersBucket(tx).GetValue(userID)
+	if err := DeleteBinding(tx, userID); err != nil {
+		return 0, err
+	}
+	if err := NewUserSettingsBucket(tx).DeleteValue(userID); err != nil {
+		return 0, err
+	}
+	follows := NewFollowsBucket(tx)
+	if err := follows.DeleteValue(userID); err != nil {
+		return 0, err
+	}
+	if err := NewPugQueueBucket(tx).DeleteValue(userID); err != nil {
+		return 0, err
+	}
+	if steamID == 0 {
+		return 0, nil
+	}
+	for _, name := range [][]byte{skillHistoryBucketName, sessionsBucketName, seedingBucketName} {
+		if err := deletePrefix(tx.Bucket(name), playerPrefix(steamID)); err != nil {
+			return 0, err
+		}
+	}
+	if err := NewOpenSessionsBucket(tx).DeleteValue(steamID); err != nil {
+		return 0, err
+	}
+	if err := NewPlaytimeBucket(tx).DeleteValue(steamID); err != nil {
+		return 0, err
+	}
+	if err := NewLastSeenBucket(tx).DeleteValue(steamID); err != nil {
+		return 0, err
+	}
+	followers := map[string][]uint32{}
+	err := follows.ForEachValue(func(followerID string, players []uint32) error {
+		if slices.Contains(players, steamID) {
+			followers[followerID] = slices.DeleteFunc(players, func(id uint32) bool { return id == steamID })
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	for followerID, players := range followers {
+		if len(players) == 0 {
+			err = follows.DeleteValue(followerID)
+		} else {
+			err = follows.PutValue(followerID, players)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return steamID, nil
+}
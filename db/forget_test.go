@@ -0,0 +1,76 @@
+package db
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+func TestForgetUser(t *testing.T) {
+	bdb := openTestDB(t)
+	Migrate(bdb, false)
+	bindTestUser(t, bdb, "1", 100, "Forgotten")
+	bindTestUser(t, bdb, "2", 200, "Kept")
+	now := time.Now().Truncate(time.Second)
+	err := bdb.Update(func(tx *bbolt.Tx) error {
+		for _, id := range []uint32{100, 200} {
+			NewSkillHistoryBucket(tx).PutValue(HistoryKey{PlayerID: id, Time: now.Add(-time.Hour)}, Skills{Marine: 1000})
+			NewSkillHistoryBucket(tx).PutValue(HistoryKey{PlayerID: id, Time: now}, Skills{Marine: 1100})
+			NewSessionsBucket(tx).PutValue(HistoryKey{PlayerID: id, Time: now.Add(-time.Hour)}, Session{Server: "A", End: now})
+			NewPlaytimeBucket(tx).PutValue(id, Playtime{"A": 3600})
+			NewLastSeenBucket(tx).PutValue(id, LastSeen{Time: now, Server: "A"})
+			NewSeedingBucket(tx).AddSeeding(id, now, 600)
+		}
+		NewUserSettingsBucket(tx).PutValue("1", UserSettings{NoAnnounce: true})
+		NewFollowsBucket(tx).PutValue("1", []uint32{200})
+		NewFollowsBucket(tx).PutValue("2", []uint32{100, 300})
+		NewFollowsBucket(tx).PutValue("3", []uint32{100})
+		steamID, err := ForgetUser(tx, "1")
+		if steamID != 100 {
+			t.Errorf("expected Steam ID 100, got %d", steamID)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bdb.View(func(tx *bbolt.Tx) error {
+		if _, err := NewUsersBucket(tx).GetValue("1"); err != ErrNotFound {
+			t.Errorf("the binding wasn't removed")
+		}
+		if _, err := NewUserSettingsBucket(tx).GetValue("1"); err != ErrNotFound {
+			t.Errorf("the settings weren't removed")
+		}
+		if len(NewSkillHistoryBucket(tx).History(100, now.Add(-time.Hour*24))) != 0 {
+			t.Errorf("the skill history wasn't removed")
+		}
+		if len(NewSkillHistoryBucket(tx).History(200, now.Add(-time.Hour*24))) != 2 {
+			t.Errorf("the skill history of another player was removed")
+		}
+		if len(NewSessionsBucket(tx).Sessions(100, time.Time{})) != 0 || len(NewSessionsBucket(tx).Sessions(200, time.Time{})) != 1 {
+			t.Errorf("unexpected sessions left")
+		}
+		if _, err := NewPlaytimeBucket(tx).GetValue(100); err != ErrNotFound {
+			t.Errorf("the playtime wasn't removed")
+		}
+		if _, err := NewLastSeenBucket(tx).GetValue(100); err != ErrNotFound {
+			t.Errorf("the last seen time wasn't removed")
+		}
+		if totals := NewSeedingBucket(tx).Totals(time.Time{}); totals[100] != 0 || totals[200] != 600 {
+			t.Errorf("unexpected seeding totals left %v", totals)
+		}
+		follows := NewFollowsBucket(tx)
+		if _, err := follows.GetValue("1"); err != ErrNotFound {
+			t.Errorf("the follows weren't removed")
+		}
+		if players, _ := follows.GetValue("2"); !slices.Equal(players, []uint32{300}) {
+			t.Errorf("unexpected follows of another user %v", players)
+		}
+		if _, err := follows.GetValue("3"); err != ErrNotFound {
+			t.Errorf("the empty follow list wasn't removed")
+		}
+		return nil
+	})
+}
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"go.etcd.io/bbolt"
+)
+
+// check finds inconsistencies between the binding buckets and fixes them in the dump. The binding bucket
+// (Discord => Steam) is considered the source of truth, the indexes are rebuilt to match it. The legacy bindings keyed
+// by user name are left for the bot to migrate. The problems that can't be fixed in the dump are returned separately.
+func (d *Dump) check() (fixable []string, unfixable []string) {
+	report := func(format string, args ...any) {
+		fixable = append(fixable, fmt.Sprintf(format, args...))
+	}
+	for userID, steamID := range d.Users {
+		owner, ok := d.SteamToDiscord[steamID]
+		switch {
+		case !ok:
+			report("user %s is bound to Steam ID %d which is missing from the reverse index", userID, steamID)
+			d.SteamToDiscord[steamID] = userID
+		case owner == userID:
+		case d.Users[owner] == steamID:
+			report("users %s and %s are both bound to Steam ID %d, unbinding %s", owner, userID, steamID, userID)
+			delete(d.Users, userID)
+		default:
+			report("Steam ID %d is indexed to user %s instead of %s", steamID, owner, userID)
+			d.SteamToDiscord[steamID] = userID
+		}
+	}
+	for steamID, userID := range d.SteamToDiscord {
+		if boundID, ok := d.Users[userID]; !ok || boundID != steamID {
+			report("orphaned reverse index entry %d => %s", steamID, userID)
+			delete(d.SteamToDiscord, steamID)
+		}
+	}
+	for userID, name := range d.Names {
+		if _, ok := d.Users[userID]; !ok {
+			report("orphaned name '%s' of unbound user %s", name, userID)
+			delete(d.Names, userID)
+		}
+	}
+	for lowercase, userID := range d.Lowercase {
+		if d.isLegacyLowercase(lowercase, userID) {
+			continue
+		}
+		if name, ok := d.Names[userID]; !ok || strings.ToLower(name) != lowercase {
+			report("orphaned lowercase index entry '%s' => %s", lowercase, userID)
+			delete(d.Lowercase, lowercase)
+		}
+	}
+	for userID, name := range d.Names {
+		if d.Lowercase[strings.ToLower(name)] != userID {
+			report("name '%s' of user %s is missing from the lowercase index", name, userID)
+			d.Lowercase[strings.ToLower(name)] = userID
+		}
+	}
+	for userID := range d.Users {
+		if _, ok := d.Names[userID]; !ok && IsSnowflake(userID) {
+			// can't be repaired here, the name is set when the user sends any command to the bot
+			unfixable = append(unfixable, fmt.Sprintf("user %s has no name", userID))
+		}
+	}
+	return
+}
+
+// Fsck checks the binding buckets for orphaned and conflicting entries and returns the problems found. If repair is
+// set the fixable problems are fixed in the same transaction, the unfixable ones are only reported.
+func Fsck(bdb *bbolt.DB, repair bool) (fixable []string, unfixable []string, err error) {
+	check := func(tx *bbolt.Tx) error {
+		dump, err := readDump(tx)
+		if err != nil {
+			return err
+		}
+		fixable, unfixable = dump.check()
+		if repair && len(fixable) > 0 {
+			return writeDump(tx, dump)
+		}
+		return nil
+	}
+	if repair {
+		err = bdb.Update(check)
+	} else {
+		err = bdb.View(check)
+	}
+	return
+}
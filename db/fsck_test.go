@@ -0,0 +1,106 @@
+package db
+
+import (
+	"testing"
+
+	"go.etcd.io/bbolt"
+)
+
+func TestRebindCleansIndexes(t *testing.T) {
+	bdb := openTestDB(t)
+	Migrate(bdb, false)
+	bdb.Update(func(tx *bbolt.Tx) error {
+		PutBinding(tx, "100000000000000001", 1, "Alice")
+		PutBinding(tx, "100000000000000001", 2, "Alice2")
+		if err := PutBinding(tx, "100000000000000002", 2, "Bob"); err != ErrSteamIDBound {
+			t.Errorf("expected ErrSteamIDBound, got %v", err)
+		}
+		return nil
+	})
+	problems, unfixable, err := Fsck(bdb, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) > 0 || len(unfixable) > 0 {
+		t.Errorf("unexpected problems after rebinding: %v %v", problems, unfixable)
+	}
+	bdb.Update(func(tx *bbolt.Tx) error {
+		return DeleteBinding(tx, "100000000000000001")
+	})
+	bdb.View(func(tx *bbolt.Tx) error {
+		for name, b := range map[string]*bbolt.Bucket{"users": tx.Bucket(discordBucketName), "reverse": tx.Bucket(steamidBucketName),
+			"names": tx.Bucket(namesBucketName), "lowercase": tx.Bucket(lowercaseBucketName)} {
+			if k, _ := b.Cursor().First(); k != nil {
+				t.Errorf("%s bucket isn't empty after unbinding", name)
+			}
+		}
+		return nil
+	})
+}
+
+func TestFsckRepair(t *testing.T) {
+	bdb := openTestDB(t)
+	Migrate(bdb, false)
+	bdb.Update(func(tx *bbolt.Tx) error {
+		PutBinding(tx, "100000000000000001", 1, "Alice")
+		NewSteamToDiscordBucket(tx).PutValue(5, "100000000000000001")    // orphaned reverse entry
+		NewUsersBucket(tx).PutValue("100000000000000002", 1)             // conflicting binding
+		NewLowercaseBucket(tx).PutValue("oldname", "100000000000000001") // stale name
+		NewUsersBucket(tx).PutValue("100000000000000003", 3)             // missing reverse entry
+		NewNamesBucket(tx).PutValue("100000000000000003", "Carol")       // missing lowercase entry
+		NewUsersBucket(tx).PutValue("100000000000000004", 4)             // no name, can't be repaired
+		NewSteamToDiscordBucket(tx).PutValue(4, "100000000000000004")
+		return nil
+	})
+	problems, unfixable, err := Fsck(bdb, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 5 || len(unfixable) != 1 {
+		t.Errorf("expected 5 repaired problems and 1 unfixable, got %v and %v", problems, unfixable)
+	}
+	if problems, unfixable, _ = Fsck(bdb, false); len(problems) > 0 || len(unfixable) != 1 {
+		t.Errorf("unexpected problems left after repair: %v %v", problems, unfixable)
+	}
+	bdb.View(func(tx *bbolt.Tx) error {
+		if userID, _ := NewSteamToDiscordBucket(tx).GetValue(1); userID != "100000000000000001" {
+			t.Errorf("Steam ID 1 should stay bound to the indexed user, got %s", userID)
+		}
+		if userID, _ := NewLowercaseBucket(tx).GetValue("carol"); userID != "100000000000000003" {
+			t.Errorf("lowercase index wasn't restored")
+		}
+		return nil
+	})
+}
+
+func TestLegacyBindingsKept(t *testing.T) {
+	bdb := openTestDB(t)
+	Migrate(bdb, false)
+	bdb.Update(func(tx *bbolt.Tx) error {
+		PutBinding(tx, "100000000000000001", 1, "Alice")
+		// a binding made before they were keyed by Discord user ID
+		NewUsersBucket(tx).PutValue("Bob#1234", 2)
+		NewSteamToDiscordBucket(tx).PutValue(2, "Bob#1234")
+		return NewLowercaseBucket(tx).PutValue("Bob#1234", "Bob#1234")
+	})
+	problems, unfixable, err := Fsck(bdb, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) > 0 || len(unfixable) > 0 {
+		t.Errorf("unexpected problems with a legacy binding: %v %v", problems, unfixable)
+	}
+	bdb.View(func(tx *bbolt.Tx) error {
+		if steamID, err := NewUsersBucket(tx).GetValue("Bob#1234"); err != nil || steamID != 2 {
+			t.Errorf("the legacy binding was removed")
+		}
+		dump, err := readDump(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := dump.Validate(); err != nil {
+			t.Errorf("a dump with a legacy binding doesn't validate: %s", err)
+		}
+		return nil
+	})
+}
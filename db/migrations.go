@@ -0,0 +1,177 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"go.etcd.io/bbolt"
+)
+
+var (
+	metaBucketName   = []byte("meta")
+	schemaVersionKey = "schema_version"
+	errDryRun        = errors.New("dry run")
+)
+
+type Migration struct {
+	Description string
+	Apply       func(tx *bbolt.Tx) error
+}
+
+// migrations must only be appended to, the schema version stored in the database is the number of applied migrations
+var migrations = []Migration{
+	{
+		Description: "create initial buckets",
+		Apply: createBuckets(discordBucketName, steamidBucketName,
+			lowercaseBucketName, memesBucketName),
+	},
+	{
+		Description: "build Steam ID => Discord index",
+		Apply: func(tx *bbolt.Tx) error {
+			discord := tx.Bucket(discordBucketName)
+			steam := tx.Bucket(steamidBucketName)
+			return discord.ForEach(func(k, v []byte) error {
+				if steam.Get(v) == nil {
+					log.Printf("Adding reverse index entry for %s", k)
+				}
+				return steam.Put(v, k)
+			})
+		},
+	},
+	{
+		Description: "create Discord names bucket",
+		Apply:       createBuckets(namesBucketName),
+	},
+	{
+		Description: "create Steam API cache bucket",
+		Apply:       createBuckets(steamCacheBucketName),
+	},
+	{
+		Description: "create skill history bucket",
+		Apply:       createBuckets(skillHistoryBucketName),
+	},
+	{
+		Description: "create user settings bucket",
+		Apply:       createBuckets(userSettingsBucketName),
+	},
+	{
+		Description: "create PUG queue bucket",
+		Apply:       createBuckets(pugQueueBucketName),
+	},
+	{
+		Description: "create last seen bucket",
+		Apply:       createBuckets(lastSeenBucketName),
+	},
+	{
+		Description: "create session and playtime buckets",
+		Apply:       createBuckets(sessionsBucketName, openSessionsBucketName, playtimeBucketName),
+	},
+	{
+		Description: "create follows bucket",
+		Apply:       createBuckets(followsBucketName),
+	},
+	{
+		Description: "create watchlist bucket",
+		Apply:       createBuckets(watchlistBucketName),
+	},
+	{
+		Description: "create seeding bucket",
+		Apply:       createBuckets(seedingBucketName),
+	},
+}
+
+func createBuckets(names ...[]byte) func(tx *bbolt.Tx) error {
+	return func(tx *bbolt.Tx) error {
+		for _, name := range names {
+			if tx.Bucket(name) != nil {
+				continue
+			}
+			log.Printf("Creating bucket %s", name)
+			if _, err := tx.CreateBucket(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+type MetaBucket struct {
+	Bucket[string, uint32]
+}
+
+func NewMetaBucket(tx *bbolt.Tx) MetaBucket {
+	return MetaBucket{
+		Bucket[string, uint32]{
+			tx.Bucket(metaBucketName),
+			StringConverter{},
+			U32Converter{},
+		}}
+}
+
+func SchemaVersion() uint32 {
+	return uint32(len(migrations))
+}
+
+func getSchemaVersion(tx *bbolt.Tx) uint32 {
+	if tx.Bucket(metaBucketName) == nil {
+		return 0
+	}
+	version, _ := NewMetaBucket(tx).GetValue(schemaVersionKey)
+	return version
+}
+
+// Migrate brings the database schema to the current version. A copy of the database file is made before any changes
+// are applied. If dryRun is set the migrations are run and logged but the transaction is rolled back.
+func Migrate(bdb *bbolt.DB, dryRun bool) error {
+	var version uint32
+	bdb.View(func(tx *bbolt.Tx) error {
+		version = getSchemaVersion(tx)
+		return nil
+	})
+	target := SchemaVersion()
+	if version > target {
+		return fmt.Errorf("database schema version %d is newer than supported version %d", version, target)
+	}
+	if version == target {
+		log.Printf("Database schema is up to date (version %d)", version)
+		return nil
+	}
+	if !dryRun {
+		backupPath := fmt.Sprintf("%s.v%d.bak", bdb.Path(), version)
+		log.Printf("Backing up database to %s", backupPath)
+		err := bdb.View(func(tx *bbolt.Tx) error {
+			return tx.CopyFile(backupPath, 0600)
+		})
+		if err != nil {
+			return fmt.Errorf("error backing up database: %w", err)
+		}
+	}
+	err := bdb.Update(func(tx *bbolt.Tx) error {
+		for i := version; i < target; i++ {
+			log.Printf("Applying migration %d: %s", i+1, migrations[i].Description)
+			if err := migrations[i].Apply(tx); err != nil {
+				return fmt.Errorf("migration %d (%s) failed: %w", i+1, migrations[i].Description, err)
+			}
+		}
+		if _, err := tx.CreateBucketIfNotExists(metaBucketName); err != nil {
+			return err
+		}
+		if err := NewMetaBucket(tx).PutValue(schemaVersionKey, target); err != nil {
+			return err
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err == errDryRun {
+		log.Printf("Dry run complete, the database would be migrated from version %d to %d", version, target)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("Database migrated from version %d to %d", version, target)
+	return nil
+}
@@ -0,0 +1,67 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.etcd.io/bbolt"
+)
+
+func openTestDB(t *testing.T) *bbolt.DB {
+	bdb, err := OpenBoltDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { CloseBoltDB(bdb) })
+	return bdb
+}
+
+func version(bdb *bbolt.DB) (result uint32) {
+	bdb.View(func(tx *bbolt.Tx) error {
+		result = getSchemaVersion(tx)
+		return nil
+	})
+	return
+}
+
+func TestMigrate(t *testing.T) {
+	bdb := openTestDB(t)
+	if err := Migrate(bdb, true); err != nil {
+		t.Fatal(err)
+	}
+	if v := version(bdb); v != 0 {
+		t.Errorf("dry run changed schema version to %d", v)
+	}
+	if _, err := os.Stat(bdb.Path() + ".v0.bak"); err == nil {
+		t.Errorf("dry run made a backup")
+	}
+	if err := Migrate(bdb, false); err != nil {
+		t.Fatal(err)
+	}
+	if v := version(bdb); v != SchemaVersion() {
+		t.Errorf("expected schema version %d, got %d", SchemaVersion(), v)
+	}
+	if _, err := os.Stat(bdb.Path() + ".v0.bak"); err != nil {
+		t.Errorf("backup not found: %s", err)
+	}
+	bdb.View(func(tx *bbolt.Tx) error {
+		for _, name := range [][]byte{discordBucketName, steamidBucketName, lowercaseBucketName, namesBucketName, memesBucketName} {
+			if tx.Bucket(name) == nil {
+				t.Errorf("bucket %s wasn't created", name)
+			}
+		}
+		return nil
+	})
+}
+
+func TestMigrateNewerSchema(t *testing.T) {
+	bdb := openTestDB(t)
+	bdb.Update(func(tx *bbolt.Tx) error {
+		tx.CreateBucket(metaBucketName)
+		return NewMetaBucket(tx).PutValue(schemaVersionKey, SchemaVersion()+1)
+	})
+	if err := Migrate(bdb, false); err == nil {
+		t.Errorf("expected an error migrating a newer schema")
+	}
+}
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+var (
+	seedingBucketName = []byte("seeding")
+)
+
+type SeedingDay struct {
+	Day     time.Time `json:"day"`
+	Seconds uint32    `json:"seconds"`
+}
+
+// SeedingBucket stores the time in seconds each player has spent seeding the servers per day, the key time is the
+// beginning of the day in UTC
+type SeedingBucket struct {
+	Bucket[HistoryKey, uint32]
+}
+
+func NewSeedingBucket(tx *bbolt.Tx) SeedingBucket {
+	return SeedingBucket{Bucket[HistoryKey, uint32]{
+		tx.Bucket(seedingBucketName),
+		HistoryKeyConverter{},
+		U32Converter{},
+	}}
+}
+
+func seedingDay(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Hour * 24)
+}
+
+// AddSeeding credits the player with the seeding time at the specified moment
+func (b SeedingBucket) AddSeeding(playerID uint32, t time.Time, seconds uint32) error {
+	key := HistoryKey{PlayerID: playerID, Time: seedingDay(t)}
+	current, _ := b.GetValue(key)
+	return b.PutValue(key, current+seconds)
+}
+
+// Totals sums up the seeding time of every player since the day the specified time belongs to
+func (b SeedingBucket) Totals(since time.Time) map[uint32]uint32 {
+	result := map[uint32]uint32{}
+	since = seedingDay(since)
+	b.ForEachValue(func(k HistoryKey, seconds uint32) error {
+		if !k.Time.Before(since) {
+			result[k.PlayerID] += seconds
+		}
+		return nil
+	})
+	return result
+}
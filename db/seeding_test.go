@@ -0,0 +1,38 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+func TestSeeding(t *testing.T) {
+	bdb := openTestDB(t)
+	Migrate(bdb, false)
+	day := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	err := bdb.Update(func(tx *bbolt.Tx) error {
+		seeding := NewSeedingBucket(tx)
+		seeding.AddSeeding(100, day.Add(time.Hour), 60)
+		seeding.AddSeeding(100, day.Add(time.Hour*23), 120)
+		seeding.AddSeeding(100, day.Add(-time.Hour), 600)
+		return seeding.AddSeeding(200, day.Add(time.Hour*2), 30)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bdb.View(func(tx *bbolt.Tx) error {
+		seeding := NewSeedingBucket(tx)
+		if seconds, _ := seeding.GetValue(HistoryKey{PlayerID: 100, Time: day}); seconds != 180 {
+			t.Errorf("expected 180 seconds seeded on the day, got %d", seconds)
+		}
+		totals := seeding.Totals(day.Add(time.Hour * 12))
+		if totals[100] != 180 || totals[200] != 30 {
+			t.Errorf("unexpected totals since the day %v", totals)
+		}
+		if totals := seeding.Totals(time.Time{}); totals[100] != 780 {
+			t.Errorf("unexpected all time totals %v", totals)
+		}
+		return nil
+	})
+}
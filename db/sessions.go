@@ -0,0 +1,114 @@
+package db
+
+import (
+	"bytes"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+var (
+	sessionsBucketName     = []byte("sessions")
+	openSessionsBucketName = []byte("open_sessions")
+	playtimeBucketName     = []byte("playtime")
+)
+
+// Session is a finished play session, it's keyed by the player and the session start
+type Session struct {
+	Server string    `json:"server"`
+	End    time.Time `json:"end"`
+}
+
+type SessionRecord struct {
+	Start time.Time `json:"start"`
+	Session
+}
+
+func (s SessionRecord) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+type SessionsBucket struct {
+	Bucket[HistoryKey, Session]
+}
+
+func NewSessionsBucket(tx *bbolt.Tx) SessionsBucket {
+	return SessionsBucket{Bucket[HistoryKey, Session]{
+		tx.Bucket(sessionsBucketName),
+		HistoryKeyConverter{},
+		StructConverter[Session]{},
+	}}
+}
+
+// Sessions returns the sessions of the player that ended after the specified time, ordered by start
+func (b SessionsBucket) Sessions(playerID uint32, since time.Time) (result []SessionRecord) {
+	prefix := playerPrefix(playerID)
+	c := b.Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		session := b.valueConverter.convertFrom(v)
+		if session.End.After(since) {
+			result = append(result, SessionRecord{Start: b.keyConverter.convertFrom(k).Time, Session: session})
+		}
+	}
+	return
+}
+
+// OpenSession is a session of a player who's still on the server
+type OpenSession struct {
+	Server string    `json:"server"`
+	Start  time.Time `json:"start"`
+}
+
+type OpenSessionsBucket struct {
+	Bucket[uint32, OpenSession]
+}
+
+func NewOpenSessionsBucket(tx *bbolt.Tx) OpenSessionsBucket {
+	return OpenSessionsBucket{Bucket[uint32, OpenSession]{
+		tx.Bucket(openSessionsBucketName),
+		U32Converter{},
+		StructConverter[OpenSession]{},
+	}}
+}
+
+// Playtime is the total time in seconds the player has spent on each server
+type Playtime map[string]int64
+
+type PlaytimeBucket struct {
+	Bucket[uint32, Playtime]
+}
+
+func NewPlaytimeBucket(tx *bbolt.Tx) PlaytimeBucket {
+	return PlaytimeBucket{Bucket[uint32, Playtime]{
+		tx.Bucket(playtimeBucketName),
+		U32Converter{},
+		StructConverter[Playtime]{},
+	}}
+}
+
+// CloseSession finishes the open session of the player, saves it and adds its duration to the player's total
+// playtime on the server
+func CloseSession(tx *bbolt.Tx, playerID uint32, end time.Time) (SessionRecord, error) {
+	open := NewOpenSessionsBucket(tx)
+	s, err := open.GetValue(playerID)
+	if err != nil {
+		return SessionRecord{}, err
+	}
+	if end.Before(s.Start) {
+		end = s.Start
+	}
+	record := SessionRecord{Start: s.Start, Session: Session{Server: s.Server, End: end}}
+	if err := NewSessionsBucket(tx).PutValue(HistoryKey{PlayerID: playerID, Time: s.Start}, record.Session); err != nil {
+		return SessionRecord{}, err
+	}
+	playtimeBucket := NewPlaytimeBucket(tx)
+	playtime, _ := playtimeBucket.GetValue(playerID)
+	if playtime == nil {
+		playtime = Playtime{}
+	}
+	playtime[s.Server] += int64(record.Duration() / time.Second)
+	if err := playtimeBucket.PutValue(playerID, playtime); err != nil {
+		return SessionRecord{}, err
+	}
+	return record, open.DeleteValue(playerID)
+}
@@ -0,0 +1,51 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+func TestSessions(t *testing.T) {
+	bdb := openTestDB(t)
+	Migrate(bdb, false)
+	now := time.Now().Truncate(time.Second)
+	err := bdb.Update(func(tx *bbolt.Tx) error {
+		open := NewOpenSessionsBucket(tx)
+		for i, s := range []OpenSession{
+			{Server: "A", Start: now.Add(-time.Hour * 50)},
+			{Server: "B", Start: now.Add(-time.Hour * 3)},
+			{Server: "A", Start: now.Add(-time.Hour)},
+		} {
+			if err := open.PutValue(1, s); err != nil {
+				return err
+			}
+			record, err := CloseSession(tx, 1, s.Start.Add(time.Hour*time.Duration(i+1)))
+			if err != nil {
+				return err
+			}
+			if record.Duration() != time.Hour*time.Duration(i+1) {
+				t.Errorf("unexpected session duration %s", record.Duration())
+			}
+		}
+		if _, err := CloseSession(tx, 1, now); err != ErrNotFound {
+			t.Errorf("expected no open session, got %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bdb.View(func(tx *bbolt.Tx) error {
+		sessions := NewSessionsBucket(tx).Sessions(1, now.Add(-time.Hour*24))
+		if len(sessions) != 2 || sessions[0].Server != "B" || sessions[1].Server != "A" {
+			t.Errorf("unexpected sessions %+v", sessions)
+		}
+		playtime, err := NewPlaytimeBucket(tx).GetValue(1)
+		if err != nil || playtime["A"] != 4*3600 || playtime["B"] != 2*3600 {
+			t.Errorf("unexpected playtime %+v (%v)", playtime, err)
+		}
+		return nil
+	})
+}
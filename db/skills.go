@@ -0,0 +1,107 @@
+package db
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+var (
+	skillHistoryBucketName = []byte("skill_history")
+	skillRefreshKey        = "skill_refresh"
+)
+
+// LastSkillRefresh returns the time the skills of all bound players were last refreshed, it's kept across restarts
+func LastSkillRefresh(tx *bbolt.Tx) time.Time {
+	t, err := NewMetaBucket(tx).GetValue(skillRefreshKey)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(int64(t), 0)
+}
+
+func SetLastSkillRefresh(tx *bbolt.Tx, t time.Time) error {
+	return NewMetaBucket(tx).PutValue(skillRefreshKey, uint32(t.Unix()))
+}
+
+// Skills is the full Hive skill breakdown of a player with the side offsets applied
+type Skills struct {
+	Marine       int `json:"marine"`
+	MarineComm   int `json:"marine_comm"`
+	Alien        int `json:"alien"`
+	AlienComm    int `json:"alien_comm"`
+	TDMarine     int `json:"td_marine"`
+	TDMarineComm int `json:"td_marine_comm"`
+	TDAlien      int `json:"td_alien"`
+	TDAlienComm  int `json:"td_alien_comm"`
+}
+
+type SkillSnapshot struct {
+	Time   time.Time `json:"time"`
+	Skills Skills    `json:"skills"`
+}
+
+type HistoryKey struct {
+	PlayerID uint32
+	Time     time.Time
+}
+
+// HistoryKeyConverter stores the keys in big endian so that all snapshots of a player are adjacent and ordered by time
+type HistoryKeyConverter struct{}
+
+func (h HistoryKeyConverter) convertTo(val HistoryKey) []byte {
+	var buf [12]byte
+	binary.BigEndian.PutUint32(buf[:4], val.PlayerID)
+	binary.BigEndian.PutUint64(buf[4:], uint64(val.Time.Unix()))
+	return buf[:]
+}
+
+func (h HistoryKeyConverter) convertFrom(val []byte) HistoryKey {
+	return HistoryKey{
+		PlayerID: binary.BigEndian.Uint32(val[:4]),
+		Time:     time.Unix(int64(binary.BigEndian.Uint64(val[4:])), 0),
+	}
+}
+
+type SkillHistoryBucket struct {
+	Bucket[HistoryKey, Skills]
+}
+
+func NewSkillHistoryBucket(tx *bbolt.Tx) SkillHistoryBucket {
+	return SkillHistoryBucket{Bucket[HistoryKey, Skills]{
+		tx.Bucket(skillHistoryBucketName),
+		HistoryKeyConverter{},
+		StructConverter[Skills]{},
+	}}
+}
+
+func playerPrefix(playerID uint32) []byte {
+	return binary.BigEndian.AppendUint32(nil, playerID)
+}
+
+// History returns the snapshots of the player made since the specified time, ordered by time. The last snapshot made
+// before that time is included as well because it's still in effect at the beginning of the period.
+func (b SkillHistoryBucket) History(playerID uint32, since time.Time) (result []SkillSnapshot) {
+	prefix := playerPrefix(playerID)
+	c := b.Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		snapshot := SkillSnapshot{Time: b.keyConverter.convertFrom(k).Time, Skills: b.valueConverter.convertFrom(v)}
+		if snapshot.Time.After(since) {
+			result = append(result, snapshot)
+		} else {
+			result = []SkillSnapshot{snapshot}
+		}
+	}
+	return
+}
+
+// Latest returns the most recent snapshot of the player
+func (b SkillHistoryBucket) Latest(playerID uint32) (SkillSnapshot, error) {
+	history := b.History(playerID, time.Now())
+	if len(history) == 0 {
+		return SkillSnapshot{}, ErrNotFound
+	}
+	return history[len(history)-1], nil
+}
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+func TestSkillHistory(t *testing.T) {
+	bdb := openTestDB(t)
+	Migrate(bdb, false)
+	now := time.Now().Truncate(time.Second)
+	bdb.Update(func(tx *bbolt.Tx) error {
+		b := NewSkillHistoryBucket(tx)
+		for i, days := range []int{30, 10, 3, 1} {
+			b.PutValue(HistoryKey{PlayerID: 1, Time: now.AddDate(0, 0, -days)}, Skills{Marine: 1000 + i})
+		}
+		b.PutValue(HistoryKey{PlayerID: 2, Time: now.AddDate(0, 0, -2)}, Skills{Marine: 2000})
+		return nil
+	})
+	bdb.View(func(tx *bbolt.Tx) error {
+		b := NewSkillHistoryBucket(tx)
+		history := b.History(1, now.AddDate(0, 0, -7))
+		if len(history) != 3 || history[0].Skills.Marine != 1001 || history[2].Skills.Marine != 1003 {
+			t.Errorf("unexpected history %+v", history)
+		}
+		if latest, err := b.Latest(1); err != nil || latest.Skills.Marine != 1003 || !latest.Time.Equal(now.AddDate(0, 0, -1)) {
+			t.Errorf("unexpected latest snapshot %+v (%v)", latest, err)
+		}
+		if _, err := b.Latest(3); err != ErrNotFound {
+			t.Errorf("expected no history for an unknown player")
+		}
+		return nil
+	})
+}
+
+func TestLastSkillRefresh(t *testing.T) {
+	bdb := openTestDB(t)
+	Migrate(bdb, false)
+	now := time.Now().Truncate(time.Second)
+	bdb.Update(func(tx *bbolt.Tx) error {
+		if !LastSkillRefresh(tx).IsZero() {
+			t.Errorf("expected no refresh time in a new database")
+		}
+		return SetLastSkillRefresh(tx, now)
+	})
+	bdb.View(func(tx *bbolt.Tx) error {
+		if last := LastSkillRefresh(tx); !last.Equal(now) {
+			t.Errorf("expected refresh time %s, got %s", now, last)
+		}
+		return nil
+	})
+}
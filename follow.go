@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.etcd.io/bbolt"
+	"rkfg.me/ns2query/db"
+)
+
+type followConfig struct {
+	Max      int           `json:"max"`
+	Cooldown time.Duration `json:"cooldown"`
+}
+
+// followNotified keeps the last notification time for every follower/player pair so a player hopping between the
+// servers or reconnecting doesn't spam the followers
+var followNotified struct {
+	sync.Mutex
+	times map[string]time.Time
+}
+
+func followNotificationAllowed(userID string, playerID uint32) bool {
+	followNotified.Lock()
+	defer followNotified.Unlock()
+	if followNotified.times == nil {
+		followNotified.times = map[string]time.Time{}
+	}
+	key := fmt.Sprintf("%s/%d", userID, playerID)
+	if time.Since(followNotified.times[key]) < config.Follow.Cooldown {
+		return false
+	}
+	followNotified.times[key] = time.Now()
+	return true
+}
+
+// notifyFollowers sends a direct message to everyone following the players who joined the server
+func (srv *ns2server) notifyFollowers(joined []uint32) {
+	if len(joined) == 0 {
+		return
+	}
+	notifications := map[string][]uint32{}
+	bdb.View(func(tx *bbolt.Tx) error {
+		return db.NewFollowsBucket(tx).ForEachValue(func(userID string, players []uint32) error {
+			for _, id := range joined {
+				if slices.Contains(players, id) && !playerSettings(tx, id).HideFromFollow {
+					notifications[userID] = append(notifications[userID], id)
+				}
+			}
+			return nil
+		})
+	})
+	for userID, players := range notifications {
+		names := []string{}
+		for _, id := range players {
+			if followNotificationAllowed(userID, id) {
+				name, _ := playerNameAvatar(id)
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 {
+			continue
+		}
+		sendChan <- message{MessageSend: &discordgo.MessageSend{Embed: &discordgo.MessageEmbed{
+			Title:       srv.mapTitle(),
+			Description: fmt.Sprintf("%s joined, connect to %s", strings.Join(names, ", "), connectAddress(srv.Address)),
+			Footer:      &discordgo.MessageEmbedFooter{Text: "Use -unfollow to stop these notifications"},
+			Color:       0x00aaff,
+		}}, userID: userID}
+	}
+}
+
+func getFollows(userID string) (result []uint32) {
+	bdb.View(func(tx *bbolt.Tx) error {
+		result, _ = db.NewFollowsBucket(tx).GetValue(userID)
+		return nil
+	})
+	return
+}
+
+func followList(author *discordgo.User) *discordgo.MessageSend {
+	follows := getFollows(author.ID)
+	if len(follows) == 0 {
+		return &discordgo.MessageSend{Content: "You don't follow anyone, use `-follow <player>` to get notified when the player joins our servers."}
+	}
+	description := &strings.Builder{}
+	for _, id := range follows {
+		name, _ := playerNameAvatar(id)
+		fmt.Fprintf(description, "%s (%d)\n", name, id)
+	}
+	return &discordgo.MessageSend{Embed: &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("You follow %d/%d players", len(follows), config.Follow.Max),
+		Description: description.String(),
+	}}
+}
+
+func follow(args []string, author *discordgo.User) (*discordgo.MessageSend, error) {
+	if len(args) == 0 {
+		return followList(author), nil
+	}
+	if len(args) > 1 {
+		return nil, fmt.Errorf("specify one player to follow")
+	}
+	switch a := strings.ToLower(args[0]); a {
+	case "hide", "show":
+		err := updateUserSettings(author.ID, func(s *db.UserSettings) {
+			s.HideFromFollow = a == "hide"
+		})
+		if err != nil {
+			return nil, err
+		}
+		if a == "hide" {
+			return &discordgo.MessageSend{Content: "Nobody will be notified when you join."}, nil
+		}
+		return &discordgo.MessageSend{Content: "Your followers will be notified when you join."}, nil
+	}
+	playerID, name, err := resolvePlayer(args[0])
+	if err != nil {
+		return nil, err
+	}
+	err = bdb.Update(func(tx *bbolt.Tx) error {
+		if playerSettings(tx, playerID).HideFromFollow {
+			return fmt.Errorf("%s doesn't want to be followed", name)
+		}
+		follows := db.NewFollowsBucket(tx)
+		players, _ := follows.GetValue(author.ID)
+		if slices.Contains(players, playerID) {
+			return fmt.Errorf("you already follow %s", name)
+		}
+		if len(players) >= config.Follow.Max {
+			return fmt.Errorf("you can follow at most %d players, use `-unfollow` to remove someone", config.Follow.Max)
+		}
+		return follows.PutValue(author.ID, append(players, playerID))
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("User %s follows player %d", author.ID, playerID)
+	return &discordgo.MessageSend{Content: fmt.Sprintf("You'll get a direct message when %s joins our servers.", name)}, nil
+}
+
+func unfollow(args []string, author *discordgo.User) (*discordgo.MessageSend, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("specify one player to unfollow")
+	}
+	playerID, name, err := resolvePlayer(args[0])
+	if err != nil {
+		return nil, err
+	}
+	err = bdb.Update(func(tx *bbolt.Tx) error {
+		follows := db.NewFollowsBucket(tx)
+		players, _ := follows.GetValue(author.ID)
+		idx := slices.Index(players, playerID)
+		if idx < 0 {
+			return fmt.Errorf("you don't follow %s", name)
+		}
+		players = slices.Delete(players, idx, idx+1)
+		if len(players) == 0 {
+			return follows.DeleteValue(author.ID)
+		}
+		return follows.PutValue(author.ID, players)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &discordgo.MessageSend{Content: fmt.Sprintf("You don't follow %s anymore.", name)}, nil
+}
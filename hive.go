@@ -1,17 +1,17 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
-	"net/http"
+	"strings"
+	"time"
 
-	"github.com/Philipp15b/go-steamapi"
 	"github.com/bwmarrin/discordgo"
+	"go.etcd.io/bbolt"
+	"rkfg.me/ns2query/db"
 )
 
 type hive struct {
-	GameName    string
 	Playerstats struct {
 		Stats []struct {
 			Name  string
@@ -20,23 +20,26 @@ type hive struct {
 	}
 }
 
-func getSkill(playerID uint32) (*discordgo.MessageSend, error) {
-	steamID := uint64(playerID) + 0x110000100000000
-	url := fmt.Sprintf("https://api.steampowered.com/ISteamUserStats/GetUserStatsForGame/v2/?key=%s&steamid=%d&appid=4920", config.SteamKey, steamID)
-	hiveResp, err := http.Get(url)
+type skillField struct {
+	name  string
+	value func(s db.Skills) int
+}
+
+var skillFields = []skillField{
+	{"Marine", func(s db.Skills) int { return s.Marine }},
+	{"Marine comm", func(s db.Skills) int { return s.MarineComm }},
+	{"Alien", func(s db.Skills) int { return s.Alien }},
+	{"Alien comm", func(s db.Skills) int { return s.AlienComm }},
+	{"TD Marine", func(s db.Skills) int { return s.TDMarine }},
+	{"TD Marine comm", func(s db.Skills) int { return s.TDMarineComm }},
+	{"TD Alien", func(s db.Skills) int { return s.TDAlien }},
+	{"TD Alien comm", func(s db.Skills) int { return s.TDAlienComm }},
+}
+
+func getSkills(playerID uint32) (result db.Skills, err error) {
+	steamData, err := steamAPI.playerStats(playerID)
 	if err != nil {
-		return nil, err
-	}
-	var steamData hive
-	json.NewDecoder(hiveResp.Body).Decode(&steamData)
-	if steamData.GameName == "" {
-		summary, err := steamapi.GetPlayerSummaries([]uint64{steamID}, config.SteamKey)
-		if err != nil || len(summary) == 0 {
-			log.Printf("Error getting player info: %s", err)
-			steamData.GameName = "<err>"
-		} else {
-			steamData.GameName = summary[0].PersonaName
-		}
+		return
 	}
 	skill := [4]int{}
 	skillOffset := [4]int{}
@@ -82,31 +85,125 @@ func getSkill(playerID uint32) (*discordgo.MessageSend, error) {
 			skillOffset[i] = -skillOffset[i]
 		}
 	}
-	return &discordgo.MessageSend{Embed: &discordgo.MessageEmbed{
+	return db.Skills{
+		Marine:       skill[0] - skillOffset[0],
+		MarineComm:   skill[1] - skillOffset[1],
+		Alien:        skill[0] + skillOffset[0],
+		AlienComm:    skill[1] + skillOffset[1],
+		TDMarine:     skill[2] - skillOffset[2],
+		TDMarineComm: skill[3] - skillOffset[3],
+		TDAlien:      skill[2] + skillOffset[2],
+		TDAlienComm:  skill[3] + skillOffset[3],
+	}, nil
+}
+
+func playerNameAvatar(playerID uint32) (name string, avatarURL string) {
+	summary, err := steamAPI.playerSummary(playerID)
+	if err != nil {
+		log.Printf("Error getting player info: %s", err)
+		return "<err>", ""
+	}
+	return summary.PersonaName, summary.SmallAvatarURL
+}
+
+func formatSkillPair(field int, comm int, baseline *db.SkillSnapshot, baseField int, baseComm int) string {
+	result := fmt.Sprintf("%d/%d", field, comm)
+	if baseline != nil {
+		result += fmt.Sprintf("\n%+d/%+d", field-baseField, comm-baseComm)
+	}
+	return result
+}
+
+// profileFields describes the player's Steam profile and activity on our servers, every piece is optional because
+// the profile can be private and the player may be unbound or never seen
+func profileFields(playerID uint32) (fields []*discordgo.MessageEmbedField) {
+	field := func(name string, value string) {
+		fields = append(fields, &discordgo.MessageEmbedField{Name: name, Value: value, Inline: true})
+	}
+	if playtime, err := steamAPI.playtime(playerID); err == nil {
+		field("NS2 hours", fmt.Sprintf("%.1f", playtime.Hours()))
+	} else {
+		field("NS2 hours", "hidden")
+	}
+	if summary, err := steamAPI.playerSummary(playerID); err == nil {
+		if summary.TimeCreated > 0 {
+			created := time.Unix(summary.TimeCreated, 0)
+			field("Account age", fmt.Sprintf("%.1f years\nsince %s", time.Since(created).Hours()/24/365, created.Format(dateFormat)))
+		}
+		if summary.CountryCode != "" {
+			field("Country", fmt.Sprintf(":flag_%s: %s", strings.ToLower(summary.CountryCode), summary.CountryCode))
+		}
+	}
+	var userID string
+	var lastSeen db.LastSeen
+	bdb.View(func(tx *bbolt.Tx) error {
+		userID, _ = db.NewSteamToDiscordBucket(tx).GetValue(playerID)
+		lastSeen, _ = db.NewLastSeenBucket(tx).GetValue(playerID)
+		return nil
+	})
+	if userID != "" {
+		field("Discord", "<@"+userID+">")
+	}
+	if lastSeen.Time.IsZero() {
+		field("Last seen", "never")
+	} else {
+		field("Last seen", fmt.Sprintf("<t:%d:R>\non %s", lastSeen.Time.Unix(), lastSeen.Server))
+	}
+	return
+}
+
+func getSkill(playerID uint32, full bool) (*discordgo.MessageSend, error) {
+	skills, err := getSkills(playerID)
+	if err != nil {
+		return nil, err
+	}
+	baseline := skillBaseline(playerID)
+	recordSkills(playerID, skills)
+	playerName, avatarURL := playerNameAvatar(playerID)
+	b := db.Skills{}
+	if baseline != nil {
+		b = baseline.Skills
+	}
+	msg := &discordgo.MessageSend{Embed: &discordgo.MessageEmbed{
 		Description: "Skill breakdown",
-		Author:      &discordgo.MessageEmbedAuthor{Name: steamData.GameName, IconURL: getPlayerAvatar(playerID)},
+		Author:      &discordgo.MessageEmbedAuthor{Name: playerName, IconURL: avatarURL},
 		Fields: []*discordgo.MessageEmbedField{
 			{
 				Name:   "Marine (field/comm)",
-				Value:  fmt.Sprintf("%d/%d", skill[0]-skillOffset[0], skill[1]-skillOffset[1]),
+				Value:  formatSkillPair(skills.Marine, skills.MarineComm, baseline, b.Marine, b.MarineComm),
 				Inline: true,
 			},
 			{
 				Name:   "Alien (field/comm)",
-				Value:  fmt.Sprintf("%d/%d", skill[0]+skillOffset[0], skill[1]+skillOffset[1]),
+				Value:  formatSkillPair(skills.Alien, skills.AlienComm, baseline, b.Alien, b.AlienComm),
 				Inline: true,
 			},
 			{},
 			{
 				Name:   "TD Marine (field/comm)",
-				Value:  fmt.Sprintf("%d/%d", skill[2]-skillOffset[2], skill[3]-skillOffset[3]),
+				Value:  formatSkillPair(skills.TDMarine, skills.TDMarineComm, baseline, b.TDMarine, b.TDMarineComm),
 				Inline: true,
 			},
 			{
 				Name:   "TD Alien (field/comm)",
-				Value:  fmt.Sprintf("%d/%d", skill[2]+skillOffset[2], skill[3]+skillOffset[3]),
+				Value:  formatSkillPair(skills.TDAlien, skills.TDAlienComm, baseline, b.TDAlien, b.TDAlienComm),
 				Inline: true,
 			},
 		},
-	}}, nil
+	}}
+	if full {
+		msg.Embed.Fields = append(msg.Embed.Fields, &discordgo.MessageEmbedField{})
+		msg.Embed.Fields = append(msg.Embed.Fields, profileFields(playerID)...)
+		if summary, err := steamAPI.playerSummary(playerID); err == nil {
+			msg.Embed.Thumbnail = &discordgo.MessageEmbedThumbnail{URL: summary.LargeAvatarURL}
+		}
+	}
+	if baseline != nil {
+		if time.Since(baseline.Time) >= skillDeltaPeriod {
+			msg.Embed.Footer = &discordgo.MessageEmbedFooter{Text: "Changes since last week"}
+		} else {
+			msg.Embed.Footer = &discordgo.MessageEmbedFooter{Text: "Changes since " + baseline.Time.Format(dateFormat)}
+		}
+	}
+	return msg, nil
 }
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	maxPushBodyLength = 1 << 16
+)
+
+type httpConfig struct {
+	Listen      string   `json:"listen"`
+	PushSecret  string   `json:"push_secret"`
+	CORSOrigins []string `json:"cors_origins"`
+	Metrics     bool     `json:"metrics"`
+}
+
+func serverByName(name string) *ns2server {
+	for _, srv := range config.Servers {
+		if srv.Name == name {
+			return srv
+		}
+	}
+	return nil
+}
+
+// handlePush accepts the player list of the server in the same format as id_url, the request should have the
+// "Authorization: Bearer <push_secret>" header
+func handlePush(w http.ResponseWriter, r *http.Request) {
+	secret := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(config.HTTP.PushSecret)) != 1 {
+		http.Error(w, "invalid secret", http.StatusUnauthorized)
+		return
+	}
+	srv := serverByName(r.PathValue("server"))
+	if srv == nil || !srv.Push {
+		http.Error(w, "unknown server", http.StatusNotFound)
+		return
+	}
+	players, err := decodePlayers(io.LimitReader(r.Body, maxPushBodyLength))
+	if err != nil {
+		http.Error(w, "invalid player list: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	// only the latest list matters, replace the one that hasn't been processed yet
+	select {
+	case <-srv.pushChan:
+	default:
+	}
+	select {
+	case srv.pushChan <- players:
+	default:
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func httpServer(restartChan chan struct{}) {
+	if config.HTTP.Listen == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	if config.HTTP.PushSecret != "" {
+		mux.HandleFunc("POST /push/{server}", handlePush)
+	}
+	mux.HandleFunc("/api/servers", withCORS(handleAPIServers))
+	mux.HandleFunc("/api/servers/{name}", withCORS(handleAPIServer))
+	mux.HandleFunc("GET /banner/{server}", handleBanner)
+	if config.HTTP.Metrics {
+		mux.HandleFunc("GET /metrics", handleMetrics)
+	}
+	srv := &http.Server{Addr: config.HTTP.Listen, Handler: mux, ReadHeaderTimeout: time.Second * 10}
+	go func() {
+		<-restartChan
+		log.Print("Restart request received, stopping the HTTP server")
+		srv.Shutdown(context.Background())
+	}()
+	// the previous instance may still be shutting down after a restart
+	var listener net.Listener
+	var err error
+	for range 10 {
+		if listener, err = net.Listen("tcp", config.HTTP.Listen); err == nil {
+			break
+		}
+		time.Sleep(time.Second)
+	}
+	if err != nil {
+		log.Printf("Error listening on %s: %s", config.HTTP.Listen, err)
+		return
+	}
+	log.Printf("Listening on %s", config.HTTP.Listen)
+	if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("HTTP server error: %s", err)
+	}
+}
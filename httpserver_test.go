@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandlePush(t *testing.T) {
+	srv := &ns2server{Name: "Push server", Push: true, pushChan: make(chan []playerInfo, 1)}
+	config.Servers = append(config.Servers, srv)
+	config.HTTP.PushSecret = "secret"
+	t.Cleanup(func() {
+		config.Servers = config.Servers[:len(config.Servers)-1]
+		config.HTTP.PushSecret = ""
+	})
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /push/{server}", handlePush)
+	push := func(server string, secret string, body string) int {
+		r := httptest.NewRequest(http.MethodPost, "/push/"+server, strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer "+secret)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w.Code
+	}
+	if code := push("Push%20server", "wrong", "[1]"); code != http.StatusUnauthorized {
+		t.Errorf("expected status 401 for a wrong secret, got %d", code)
+	}
+	if code := push("Nonexistent", "secret", "[1]"); code != http.StatusNotFound {
+		t.Errorf("expected status 404 for an unknown server, got %d", code)
+	}
+	if code := push("Push%20server", "secret", "{"); code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an invalid body, got %d", code)
+	}
+	for _, body := range []string{"[1, 2]", "[3]"} {
+		if code := push("Push%20server", "secret", body); code != http.StatusNoContent {
+			t.Errorf("expected status 204, got %d", code)
+		}
+	}
+	if ids := playerIDsOf(<-srv.pushChan); !slices.Equal(ids, []uint32{3}) {
+		t.Errorf("expected only the latest list to be queued, got %v", ids)
+	}
+}
+
+func TestAPIServers(t *testing.T) {
+	srv := &ns2server{Name: "API server", PlayerSlots: 20, SpecSlots: 4, currentMap: "ns2_veil", avgSkill: 1500,
+		players: []string{"A", "B"}, regularNames: []string{"A"},
+		playerList: []playerInfo{{ID: 1, Team: teamMarines, Commander: true, Name: "A", extended: true}, {ID: 2, Team: teamAliens, extended: true}}}
+	config.Servers = append(config.Servers, srv)
+	config.HTTP.CORSOrigins = []string{"https://example.com"}
+	t.Cleanup(func() {
+		config.Servers = config.Servers[:len(config.Servers)-1]
+		config.HTTP.CORSOrigins = nil
+	})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/servers", withCORS(handleAPIServers))
+	mux.HandleFunc("/api/servers/{name}", withCORS(handleAPIServer))
+	get := func(method string, path string, origin string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+	w := get(http.MethodGet, "/api/servers/API%20server", "https://example.com")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if origin := w.Header().Get("Access-Control-Allow-Origin"); origin != "https://example.com" {
+		t.Errorf("unexpected allowed origin '%s'", origin)
+	}
+	var status apiServerStatus
+	if err := json.NewDecoder(w.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	if status.Map != "ns2_veil" || status.Players != 2 || status.FreeSlots != 22 || !status.Up || status.Teams == nil ||
+		status.Teams.Marines != 1 || len(status.Teams.Commanders) != 1 {
+		t.Errorf("unexpected status %+v", status)
+	}
+	if w := get(http.MethodGet, "/api/servers", "https://other.com"); w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("unexpected allowed origin for an unknown origin")
+	} else {
+		var servers []apiServerStatus
+		if err := json.NewDecoder(w.Body).Decode(&servers); err != nil || len(servers) != len(config.Servers) {
+			t.Errorf("unexpected server list %v: %v", servers, err)
+		}
+	}
+	if w := get(http.MethodGet, "/api/servers/Nonexistent", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for an unknown server, got %d", w.Code)
+	}
+	if w := get(http.MethodOptions, "/api/servers", "https://example.com"); w.Code != http.StatusNoContent {
+		t.Errorf("expected status 204 for a preflight request, got %d", w.Code)
+	}
+	if w := get(http.MethodPost, "/api/servers", ""); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status 405 for POST, got %d", w.Code)
+	}
+}
+
+func TestHandleMetrics(t *testing.T) {
+	srv := &ns2server{Name: "Metrics server", players: []string{"A", "B", "C"}, avgSkill: 1234}
+	config.Servers = append(config.Servers, srv)
+	t.Cleanup(func() {
+		config.Servers = config.Servers[:len(config.Servers)-1]
+	})
+	commandsCounter.inc("skill")
+	commandsCounter.inc("skill")
+	parseFields(nil, []string{"Version"}, nil, "")
+	parseFields(nil, []string{"nosuchcommand"}, nil, "")
+	competitionRunsCounter.inc("a\"b\\c\td\n")
+	w := httptest.NewRecorder()
+	handleMetrics(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+	for _, line := range []string{
+		"# TYPE ns2query_server_players gauge",
+		`ns2query_server_players{server="Metrics server"} 3`,
+		`ns2query_server_average_skill{server="Metrics server"} 1234`,
+		`ns2query_server_up{server="Metrics server"} 1`,
+		"# TYPE ns2query_commands_total counter",
+		`ns2query_commands_total{command="skill"} 2`,
+		`ns2query_commands_total{command="version"} 1`,
+		"ns2query_meme_competition_runs_total{channel=\"a\\\"b\\\\c\td\\n\"} 1",
+		"ns2query_messages_sent_total 0",
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("metrics don't contain '%s':\n%s", line, body)
+		}
+	}
+	if strings.Contains(body, "nosuchcommand") {
+		t.Errorf("unknown commands shouldn't be counted:\n%s", body)
+	}
+}
+
+func TestHandleBanner(t *testing.T) {
+	srv := &ns2server{Name: "Banner server", PlayerSlots: 20, SpecSlots: 4, currentMap: "ns2_summit", avgSkill: 1800,
+		players: []string{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J", "K", "L", "M", "N"}}
+	config.Servers = append(config.Servers, srv)
+	config.QueryInterval = time.Minute
+	t.Cleanup(func() {
+		config.Servers = config.Servers[:len(config.Servers)-1]
+		config.QueryInterval = 0
+	})
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /banner/{server}", handleBanner)
+	get := func(server string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/banner/"+server, nil))
+		return w
+	}
+	if w := get("Nonexistent"); w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for an unknown server, got %d", w.Code)
+	}
+	w := get("Banner%20server")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("unexpected response %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	data := w.Body.Bytes()
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != bannerWidth || img.Bounds().Dy() != bannerHeight {
+		t.Errorf("unexpected banner size %v", img.Bounds())
+	}
+	srv.avgSkill = 0
+	if w := get("Banner%20server"); !bytes.Equal(w.Body.Bytes(), data) {
+		t.Errorf("the banner wasn't cached")
+	}
+}
+
+func TestHandlersConcurrentWithUpdates(t *testing.T) {
+	srv := &ns2server{Name: "Race server", PlayerSlots: 2, SpecSlots: 1}
+	config.Servers = append(config.Servers, srv)
+	t.Cleanup(func() {
+		config.Servers = config.Servers[:len(config.Servers)-1]
+	})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 100 {
+			srv.lock.Lock()
+			srv.players = append([]string{}, strings.Repeat("A", i%5))
+			srv.playerList = []playerInfo{{ID: uint32(i), extended: true}}
+			srv.regularNames = []string{"A"}
+			srv.avgSkill = i
+			srv.currentMap = strings.Repeat("m", i%5)
+			srv.failures = i % 3
+			srv.lock.Unlock()
+		}
+	}()
+	for range 20 {
+		handleMetrics(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		handleAPIServers(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/servers", nil))
+		srv.serverStatus()
+		srv.mapTitle()
+		if status := srv.currentStatus(); status.FreeSlots < 0 {
+			t.Errorf("negative free slots %d", status.FreeSlots)
+		}
+	}
+	<-done
+	srv.players = []string{"A", "B", "C", "D", "E"}
+	if status := srv.currentStatus(); status.FreeSlots != 0 {
+		t.Errorf("expected no free slots on an overfilled server, got %d", status.FreeSlots)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"go.etcd.io/bbolt"
+	"rkfg.me/ns2query/db"
+)
+
+const (
+	defaultTopSize = 10
+	maxTopSize     = 25
+)
+
+var topCategories = map[string]skillField{
+	"skill":  {"Skill", func(s db.Skills) int { return (s.Marine + s.Alien) / 2 }},
+	"marine": {"Marine skill", func(s db.Skills) int { return s.Marine }},
+	"alien":  {"Alien skill", func(s db.Skills) int { return s.Alien }},
+	"comm":   {"Commander skill", func(s db.Skills) int { return (s.MarineComm + s.AlienComm) / 2 }},
+	"td":     {"Thunderdome skill", func(s db.Skills) int { return (s.TDMarine + s.TDAlien) / 2 }},
+}
+
+type topEntry struct {
+	name  string
+	skill int
+}
+
+func getUserSettings(userID string) (result db.UserSettings) {
+	bdb.View(func(tx *bbolt.Tx) error {
+		result, _ = db.NewUserSettingsBucket(tx).GetValue(userID)
+		return nil
+	})
+	return
+}
+
+func updateUserSettings(userID string, update func(s *db.UserSettings)) error {
+	return bdb.Update(func(tx *bbolt.Tx) error {
+		settingsBucket := db.NewUserSettingsBucket(tx)
+		settings, _ := settingsBucket.GetValue(userID)
+		update(&settings)
+		return settingsBucket.PutValue(userID, settings)
+	})
+}
+
+// topPlayers uses the latest recorded skills of the bound players, they're refreshed in the background
+func topPlayers(category skillField, size int) (result []topEntry) {
+	bdb.View(func(tx *bbolt.Tx) error {
+		history := db.NewSkillHistoryBucket(tx)
+		settings := db.NewUserSettingsBucket(tx)
+		return db.NewSteamToDiscordBucket(tx).ForEachValue(func(playerID uint32, userID string) error {
+			if s, _ := settings.GetValue(userID); s.HideFromLeaderboard || s.NoHistory {
+				return nil
+			}
+			latest, err := history.Latest(playerID)
+			if err != nil || category.value(latest.Skills) <= 0 {
+				return nil
+			}
+			result = append(result, topEntry{name: getBindName(tx, userID), skill: category.value(latest.Skills)})
+			return nil
+		})
+	})
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].skill > result[j].skill
+	})
+	if len(result) > size {
+		result = result[:size]
+	}
+	return
+}
+
+func top(args []string, author *discordgo.User) (*discordgo.MessageSend, error) {
+	category := topCategories["skill"]
+	size := defaultTopSize
+	for _, a := range args {
+		switch a = strings.ToLower(a); a {
+		case "hide", "show":
+			err := updateUserSettings(author.ID, func(s *db.UserSettings) {
+				s.HideFromLeaderboard = a == "hide"
+			})
+			if err != nil {
+				return nil, err
+			}
+			if a == "hide" {
+				return &discordgo.MessageSend{Content: "You won't be shown on the leaderboard."}, nil
+			}
+			return &discordgo.MessageSend{Content: "You will be shown on the leaderboard."}, nil
+		}
+		if c, ok := topCategories[a]; ok {
+			category = c
+			continue
+		}
+		n, err := strconv.Atoi(a)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid argument '%s' for `-top`", a)
+		}
+		size = min(n, maxTopSize)
+	}
+	entries := topPlayers(category, size)
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no bound players with known skill yet")
+	}
+	description := &strings.Builder{}
+	for i, e := range entries {
+		fmt.Fprintf(description, "%d. %s — %d\n", i+1, e.name, e.skill)
+	}
+	return &discordgo.MessageSend{Embed: &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("Top %d by %s", len(entries), strings.ToLower(category.name)),
+		Description: description.String(),
+		Footer:      &discordgo.MessageEmbedFooter{Text: "Use -top hide to hide yourself from the leaderboard"},
+	}}, nil
+}
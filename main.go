@@ -16,11 +16,17 @@ func main() {
 	usage := `Usage:
 	ns2query [-c config]
 	ns2query --reindex
+	ns2query --migrate-dry-run [-c config]
+	ns2query --export <file> [-c config]
+	ns2query --import <file> [--merge] [-c config]
+	ns2query --fsck [--repair] [-c config]
 	ns2query -h
 
 Options:
 	-h --help    This help
 	-c config    Use config file [default: config.json]
+	--merge      Keep the existing data when importing
+	--repair     Fix the problems found by --fsck
 `
 	opts, err := docopt.ParseDoc(usage)
 	if err != nil {
@@ -33,8 +39,44 @@ Options:
 		if bdb, err = db.OpenBoltDB(config.BoltDBPath); err != nil {
 			log.Fatal("error opening BoltDB database:", err)
 		}
-		db.InitBoltDB(bdb)
 		defer db.CloseBoltDB(bdb)
+		dryRun, _ := opts.Bool("--migrate-dry-run")
+		if err = db.Migrate(bdb, dryRun); err != nil {
+			log.Fatal("error migrating database:", err)
+		}
+		if dryRun {
+			return
+		}
+	}
+	for _, mode := range []string{"--fsck", "--export", "--import", "--reindex"} {
+		if b, _ := opts.Bool(mode); b && bdb == nil {
+			log.Fatalf("%s requires the database, set bdb_database_path in the config", mode)
+		}
+	}
+	if b, _ := opts.Bool("--fsck"); b {
+		repair, _ := opts.Bool("--repair")
+		fixable, unfixable, err := db.Fsck(bdb, repair)
+		if err != nil {
+			log.Fatal("error checking database:", err)
+		}
+		for _, p := range append(fixable, unfixable...) {
+			log.Println(p)
+		}
+		log.Print(fsckSummary(fixable, unfixable, repair))
+		return
+	}
+	if b, _ := opts.Bool("--export"); b {
+		if err = exportDB(opts["<file>"].(string)); err != nil {
+			log.Fatal("error exporting database:", err)
+		}
+		return
+	}
+	if b, _ := opts.Bool("--import"); b {
+		merge, _ := opts.Bool("--merge")
+		if err = importDB(opts["<file>"].(string), merge); err != nil {
+			log.Fatal("error importing database:", err)
+		}
+		return
 	}
 	if b, _ := opts.Bool("--reindex"); b {
 		db.Reindex(bdb)
@@ -88,6 +88,7 @@ func announceMOTD(s *discordgo.Session, channelID string, deadlineHour int, dayL
 	if err != nil {
 		return err
 	}
+	competitionRunsCounter.inc(channelID)
 	if len(winners) == 0 {
 		return nil
 	}
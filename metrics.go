@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// counter is a Prometheus counter with an optional label, the label values are the map keys
+type counter struct {
+	sync.Mutex
+	name   string
+	help   string
+	label  string
+	values map[string]uint64
+}
+
+func newCounter(name string, help string, label string) *counter {
+	return &counter{name: name, help: help, label: label, values: map[string]uint64{}}
+}
+
+func (c *counter) inc(labelValue string) {
+	c.Lock()
+	defer c.Unlock()
+	c.values[labelValue]++
+}
+
+// labelEscaper escapes the label values for the Prometheus text format, only the backslash, the double quote and the
+// line feed are escaped there
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func (c *counter) write(w io.Writer) {
+	c.Lock()
+	defer c.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	if c.label == "" {
+		fmt.Fprintf(w, "%s %d\n", c.name, c.values[""])
+		return
+	}
+	labelValues := []string{}
+	for v := range c.values {
+		labelValues = append(labelValues, v)
+	}
+	sort.Strings(labelValues)
+	for _, v := range labelValues {
+		fmt.Fprintf(w, "%s{%s=\"%s\"} %d\n", c.name, c.label, labelEscaper.Replace(v), c.values[v])
+	}
+}
+
+var (
+	messagesSentCounter    = newCounter("ns2query_messages_sent_total", "Discord messages and reactions sent.", "")
+	messageRetriesCounter  = newCounter("ns2query_message_retries_total", "Failed Discord sends that were retried or given up.", "")
+	commandsCounter        = newCounter("ns2query_commands_total", "Commands handled by name.", "command")
+	repostsCounter         = newCounter("ns2query_reposts_total", "Repost detections by kind.", "kind")
+	competitionRunsCounter = newCounter("ns2query_meme_competition_runs_total", "Meme competition winner selections by channel.", "channel")
+)
+
+type serverGauge struct {
+	name  string
+	help  string
+	value func(srv *ns2server) float64
+}
+
+var serverGauges = []serverGauge{
+	{"ns2query_server_players", "Players on the server.", func(srv *ns2server) float64 { return float64(len(srv.players)) }},
+	{"ns2query_server_average_skill", "Average skill of the players on the server.", func(srv *ns2server) float64 { return float64(srv.avgSkill) }},
+	{"ns2query_server_up", "1 if the server is up, 0 if it's considered down.", func(srv *ns2server) float64 {
+		if srv.failures > config.FailureLimit && srv.downSince != nil {
+			return 0
+		}
+		return 1
+	}},
+	{"ns2query_server_failures", "Consecutive failed queries.", func(srv *ns2server) float64 { return float64(srv.failures) }},
+	{"ns2query_server_query_seconds", "Duration of the last server query.", func(srv *ns2server) float64 { return srv.queryDuration.Seconds() }},
+}
+
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	for _, g := range serverGauges {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", g.name, g.help, g.name)
+		for _, srv := range config.Servers {
+			srv.lock.RLock()
+			value := g.value(srv)
+			srv.lock.RUnlock()
+			fmt.Fprintf(w, "%s{server=\"%s\"} %g\n", g.name, labelEscaper.Replace(srv.Name), value)
+		}
+	}
+	for _, c := range []*counter{messagesSentCounter, messageRetriesCounter, commandsCounter, repostsCounter, competitionRunsCounter} {
+		c.write(w)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// team indexes as used by the game
+const (
+	teamReadyRoom = iota
+	teamMarines
+	teamAliens
+	teamSpectators
+)
+
+var teamNames = map[int]string{
+	teamReadyRoom:  "ready room",
+	teamMarines:    "marines",
+	teamAliens:     "aliens",
+	teamSpectators: "spectators",
+}
+
+// playerInfo is a player from id_url or the push endpoint, only the ID is known if the plain array format is used
+type playerInfo struct {
+	ID        uint32 `json:"id"`
+	Name      string `json:"name"`
+	Team      int    `json:"team"`
+	Commander bool   `json:"commander"`
+	Score     int    `json:"score"`
+	Connected int    `json:"connected"` // seconds since the player connected
+	extended  bool
+}
+
+type playerList struct {
+	Players []playerInfo `json:"players"`
+}
+
+// decodePlayers reads the player list in the format used by both id_url and the push endpoint. It's either a plain
+// array of Steam IDs or an object with the player details.
+func decodePlayers(r io.Reader) ([]playerInfo, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(raw), []byte("[")) {
+		var ids []uint32
+		if err := json.Unmarshal(raw, &ids); err != nil {
+			return nil, err
+		}
+		result := make([]playerInfo, len(ids))
+		for i, id := range ids {
+			result[i].ID = id
+		}
+		return result, nil
+	}
+	var list playerList
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, err
+	}
+	for i, p := range list.Players {
+		if p.ID == 0 {
+			return nil, fmt.Errorf("player %d has no Steam ID", i)
+		}
+		list.Players[i].extended = true
+	}
+	return list.Players, nil
+}
+
+func playerIDsOf(players []playerInfo) []uint32 {
+	result := make([]uint32, len(players))
+	for i, p := range players {
+		result[i] = p.ID
+	}
+	return result
+}
+
+// teamSummary counts the players on each side and lists the commanders, ok is false if the details are unknown, the
+// caller must hold srv.lock
+func (srv *ns2server) teamSummary() (marines int, aliens int, commanders []string, ok bool) {
+	for _, p := range srv.playerList {
+		if !p.extended {
+			return 0, 0, nil, false
+		}
+		ok = true
+		switch p.Team {
+		case teamMarines:
+			marines++
+		case teamAliens:
+			aliens++
+		}
+		if p.Commander && (p.Team == teamMarines || p.Team == teamAliens) {
+			commanders = append(commanders, fmt.Sprintf("%s (%s)", p.Name, teamNames[p.Team]))
+		}
+	}
+	return
+}
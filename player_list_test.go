@@ -0,0 +1,33 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDecodePlayers(t *testing.T) {
+	players, err := decodePlayers(strings.NewReader(" [1, 2]"))
+	if err != nil || !slices.Equal(playerIDsOf(players), []uint32{1, 2}) || players[0].extended {
+		t.Errorf("unexpected players from the plain array %+v (%v)", players, err)
+	}
+	players, err = decodePlayers(strings.NewReader(`{"players": [
+		{"id": 1, "name": "Marine", "team": 1, "commander": true, "score": 10, "connected": 600},
+		{"id": 2, "name": "Alien", "team": 2, "score": 5, "connected": 60},
+		{"id": 3, "name": "Spectator", "team": 3}
+	]}`))
+	if err != nil || len(players) != 3 || !players[0].extended || players[0].Name != "Marine" || players[1].Score != 5 {
+		t.Fatalf("unexpected players from the extended format %+v (%v)", players, err)
+	}
+	srv := &ns2server{playerList: players}
+	marines, aliens, commanders, ok := srv.teamSummary()
+	if !ok || marines != 1 || aliens != 1 || !slices.Equal(commanders, []string{"Marine (marines)"}) {
+		t.Errorf("unexpected team summary %d vs %d, %v", marines, aliens, commanders)
+	}
+	if _, err = decodePlayers(strings.NewReader(`{"players": [{"name": "No ID"}]}`)); err == nil {
+		t.Errorf("expected an error for a player without ID")
+	}
+	if _, _, _, ok = (&ns2server{playerList: []playerInfo{{ID: 1}}}).teamSummary(); ok {
+		t.Errorf("expected no team summary for the plain format")
+	}
+}
@@ -1,66 +1,137 @@
 package main
 
 import (
+	"errors"
 	"fmt"
-	"log"
+	"math"
 	"regexp"
 	"strconv"
+	"strings"
 
-	"github.com/Philipp15b/go-steamapi"
+	"github.com/bwmarrin/discordgo"
 	"go.etcd.io/bbolt"
 	"rkfg.me/ns2query/db"
 )
 
-var (
-	vanityRegex  = regexp.MustCompile(`https://steamcommunity.com/id/([^/]*)/?`)
-	profileRegex = regexp.MustCompile(`https://steamcommunity.com/profiles/(\d*)/?`)
+const (
+	steamID64Base = 0x0110000100000000
 )
 
+type steamIDFormat struct {
+	name  string
+	regex *regexp.Regexp
+	parse func(match []string) (playerID uint32, vanityName string, err error)
+}
+
+// steamIDFormats are tried in order, the first matching one is used
+var steamIDFormats = []steamIDFormat{
+	{"Steam profile URL", regexp.MustCompile(`(?i)^(?:https?://)?(?:www\.)?steamcommunity\.com/profiles/(\d+)/?(?:[?#].*)?$`), parseSteamID64},
+	{"Steam vanity URL", regexp.MustCompile(`(?i)^(?:https?://)?(?:www\.)?steamcommunity\.com/id/([^/?#\s]+)/?(?:[?#].*)?$`), parseVanityName},
+	{"NS2 Hive profile URL", regexp.MustCompile(`(?i)^(?:https?://)?hive\d?\.naturalselection2\.com/profile/(\d+)/?(?:[?#].*)?$`), parseAccountID},
+	{"NS2 Observatory profile URL", regexp.MustCompile(`(?i)^(?:https?://)?(?:www\.)?observatory\.morrolan\.ch/player/(\d+)/?(?:[?#].*)?$`), parseAccountID},
+	// SteamID2 is also looked up inside of longer strings such as the console status lines, SteamID3 is only found there
+	// as a separate bracketed word
+	{"SteamID2", regexp.MustCompile(`STEAM_[0-5]:([01]):(\d+)`), parseSteamID2},
+	{"SteamID3", regexp.MustCompile(`^\[?U:1:(\d+)\]?$`), parseAccountID},
+	{"SteamID3", regexp.MustCompile(`(?:^|\s)\[U:1:(\d+)\](?:\s|$)`), parseAccountID},
+	{"SteamID64", regexp.MustCompile(`^(7656\d{13})$`), parseSteamID64},
+	{"Steam account ID", regexp.MustCompile(`^(\d{1,10})$`), parseAccountID},
+	{"Steam vanity name", regexp.MustCompile(`^([A-Za-z0-9_-]{3,32})$`), parseVanityName},
+}
+
+func parseAccountID(match []string) (uint32, string, error) {
+	id, err := strconv.ParseUint(match[1], 10, 64)
+	if err != nil || id == 0 {
+		return 0, "", fmt.Errorf("invalid account ID %s", match[1])
+	}
+	if id > math.MaxUint32 {
+		return parseSteamID64(match)
+	}
+	return uint32(id), "", nil
+}
+
+func parseSteamID64(match []string) (uint32, string, error) {
+	id, err := strconv.ParseUint(match[1], 10, 64)
+	if err != nil || id <= steamID64Base || id-steamID64Base > math.MaxUint32 {
+		return 0, "", fmt.Errorf("%s is out of the individual account range", match[1])
+	}
+	return uint32(id - steamID64Base), "", nil
+}
+
+func parseSteamID2(match []string) (uint32, string, error) {
+	z, err := strconv.ParseUint(match[2], 10, 32)
+	if err != nil || z > math.MaxUint32/2 {
+		return 0, "", fmt.Errorf("invalid account number %s", match[2])
+	}
+	y, _ := strconv.ParseUint(match[1], 10, 32)
+	return uint32(z*2 + y), "", nil
+}
+
+func parseVanityName(match []string) (uint32, string, error) {
+	return 0, match[1], nil
+}
+
+// parseSteamID recognizes the Steam ID formats that don't require querying Steam. If the input can only be resolved
+// online the vanity name is returned instead of the player ID. The recognized format name is returned in both cases.
+func parseSteamID(player string) (playerID uint32, vanityName string, format string, err error) {
+	player = strings.TrimSpace(player)
+	for _, f := range steamIDFormats {
+		match := f.regex.FindStringSubmatch(player)
+		if match == nil {
+			continue
+		}
+		playerID, vanityName, err = f.parse(match)
+		if err != nil {
+			err = fmt.Errorf("recognized %s but %w", f.name, err)
+		}
+		return playerID, vanityName, f.name, err
+	}
+	return 0, "", "", fmt.Errorf("'%s' isn't a Steam ID, profile URL or vanity name", player)
+}
+
+// playerIDFromArgs resolves the command arguments to a player: no arguments means the author, `!name` is a bound
+// Discord user and anything else is parsed as a Steam ID
+func playerIDFromArgs(args []string, author *discordgo.User) (uint32, error) {
+	if len(args) == 0 {
+		return getBind(author.ID)
+	}
+	if strings.HasPrefix(args[0], discordPrefix) {
+		return playerIDFromDiscordName(strings.TrimPrefix(strings.ToLower(strings.Join(args, " ")), discordPrefix))
+	}
+	return playerIDFromSteamID(strings.Join(args, " "))
+}
+
 func playerIDFromDiscordName(username string) (uint32, error) {
-	var discordName string
+	var userID string
 	err := bdb.View(func(t *bbolt.Tx) (err error) {
-		discordName, err = db.NewLowercaseBucket(t).FindFirstValue(username)
+		userID, err = db.NewLowercaseBucket(t).FindFirstValue(username)
 		return
 	})
 	if err != nil {
 		return 0, fmt.Errorf("discord user name starting with '%s' was not found", username)
 	}
-	return getBind(discordName)
+	return getBind(userID)
 }
 
 func playerIDFromSteamID(player string) (uint32, error) {
-	vanityName := vanityRegex.FindStringSubmatch(player)
-	if vanityName != nil {
-		player = vanityName[1]
-	} else {
-		profileID := profileRegex.FindStringSubmatch(player)
-		if profileID != nil {
-			player = profileID[1]
-		}
-	}
-	steamid, err := steamapi.NewIdFromString(player)
+	playerID, vanityName, format, err := parseSteamID(player)
 	if err != nil {
-		steamid, err = steamapi.NewIdFromVanityUrl(player, config.SteamKey)
-		if err != nil {
-			id64, err := strconv.ParseUint(player, 10, 64)
-			if err != nil {
-				return 0, fmt.Errorf("steam ID %s not found", player)
+		return 0, err
+	}
+	if vanityName == "" {
+		if format == "Steam account ID" {
+			// an all-numeric vanity name looks like an account ID, it's resolved if there's no such account
+			if _, err := steamAPI.playerSummary(playerID); errors.Is(err, errPlayerNotFound) {
+				if vanityID, err := steamAPI.resolveVanity(strings.TrimSpace(player)); err == nil {
+					return vanityID, nil
+				}
 			}
-			steamid = steamapi.NewIdFrom64bit(id64)
 		}
+		return playerID, nil
 	}
-	return steamid.As32Bit(), nil
-}
-
-func getPlayerAvatar(playerID uint32) string {
-	sum, err := steamapi.GetPlayerSummaries([]uint64{steamapi.NewIdFrom32bit(playerID).As64Bit()}, config.SteamKey)
+	playerID, err = steamAPI.resolveVanity(vanityName)
 	if err != nil {
-		log.Printf("Error getting avatar for player %d: %s", playerID, err)
-		return ""
-	}
-	if len(sum) > 0 {
-		return sum[0].SmallAvatarURL
+		return 0, fmt.Errorf("recognized %s but '%s' couldn't be resolved: %w", format, vanityName, err)
 	}
-	log.Printf("No data found for player %d", playerID)
-	return ""
+	return playerID, nil
 }
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestParseSteamID(t *testing.T) {
+	tests := []struct {
+		input    string
+		playerID uint32
+		vanity   string
+		format   string
+		err      string
+	}{
+		{input: "STEAM_0:1:12345", playerID: 24691, format: "SteamID2"},
+		{input: "STEAM_1:0:12345", playerID: 24690, format: "SteamID2"},
+		{input: "[U:1:24691]", playerID: 24691, format: "SteamID3"},
+		{input: "U:1:24691", playerID: 24691, format: "SteamID3"},
+		{input: "76561197960290419", playerID: 24691, format: "SteamID64"},
+		{input: "24691", playerID: 24691, format: "Steam account ID"},
+		{input: "https://steamcommunity.com/profiles/76561197960290419", playerID: 24691, format: "Steam profile URL"},
+		{input: "steamcommunity.com/profiles/76561197960290419/", playerID: 24691, format: "Steam profile URL"},
+		{input: "http://www.steamcommunity.com/profiles/76561197960290419?l=english", playerID: 24691, format: "Steam profile URL"},
+		{input: "https://steamcommunity.com/id/someplayer/", vanity: "someplayer", format: "Steam vanity URL"},
+		{input: "steamcommunity.com/id/someplayer", vanity: "someplayer", format: "Steam vanity URL"},
+		{input: "www.steamcommunity.com/id/some_player", vanity: "some_player", format: "Steam vanity URL"},
+		{input: "someplayer", vanity: "someplayer", format: "Steam vanity name"},
+		{input: "ab", err: "isn't a Steam ID"},
+		{input: "http://hive.naturalselection2.com/profile/24691", playerID: 24691, format: "NS2 Hive profile URL"},
+		{input: "hive2.naturalselection2.com/profile/76561197960290419", playerID: 24691, format: "NS2 Hive profile URL"},
+		{input: "https://observatory.morrolan.ch/player/24691", playerID: 24691, format: "NS2 Observatory profile URL"},
+		{input: `# 2 "Some Player" STEAM_0:1:12345 01:23 50 0 active`, playerID: 24691, format: "SteamID2"},
+		{input: "Some Player [U:1:24691] 12 ms", playerID: 24691, format: "SteamID3"},
+		{input: "foo[U:1:24691]bar", err: "isn't a Steam ID"},
+		{input: "Some Player U:1:24691 12 ms", err: "isn't a Steam ID"},
+		{input: "  STEAM_0:1:12345  ", playerID: 24691, format: "SteamID2"},
+		{input: "https://steamcommunity.com/profiles/123", format: "Steam profile URL", err: "out of the individual account range"},
+		{input: "76560000000000000", format: "SteamID64", err: "out of the individual account range"},
+		{input: "0", format: "Steam account ID", err: "invalid account ID"},
+		{input: "STEAM_0:1:9999999999", format: "SteamID2", err: "invalid account number"},
+		{input: "not a steam id", err: "isn't a Steam ID"},
+		{input: "https://example.com/id/someplayer", err: "isn't a Steam ID"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			playerID, vanity, format, err := parseSteamID(tt.input)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("expected error containing '%s', got %v", tt.err, err)
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+			if playerID != tt.playerID {
+				t.Errorf("expected player ID %d, got %d", tt.playerID, playerID)
+			}
+			if vanity != tt.vanity {
+				t.Errorf("expected vanity name '%s', got '%s'", tt.vanity, vanity)
+			}
+			if format != tt.format {
+				t.Errorf("expected format '%s', got '%s'", tt.format, format)
+			}
+		})
+	}
+}
+
+func TestNumericVanityName(t *testing.T) {
+	steamAPI = testSteamClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ISteamUser/GetPlayerSummaries/v0002/":
+			if r.URL.Query().Get("steamids") == "76561197960290419" {
+				fmt.Fprint(w, `{"response": {"players": [{"steamid": "76561197960290419", "personaname": "Someone"}]}}`)
+			} else {
+				fmt.Fprint(w, `{"response": {"players": []}}`)
+			}
+		case "/ISteamUser/ResolveVanityURL/v0001/":
+			if r.URL.Query().Get("vanityurl") == "1337" {
+				fmt.Fprint(w, `{"response": {"steamid": "76561197960290420", "success": 1}}`)
+			} else {
+				fmt.Fprint(w, `{"response": {"success": 42, "message": "No match"}}`)
+			}
+		}
+	})
+	t.Cleanup(func() { steamAPI = nil })
+	for input, expected := range map[string]uint32{"24691": 24691, "1337": 24692, "4242": 4242} {
+		if playerID, err := playerIDFromSteamID(input); err != nil || playerID != expected {
+			t.Errorf("expected player ID %d for %s, got %d (%v)", expected, input, playerID, err)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/rumblefrog/go-a2s"
+)
+
+// findServer looks up the server by the case-insensitive name prefix, the only server with player IDs is used if the name
+// is empty
+func findServer(name string) (*ns2server, error) {
+	name = strings.ToLower(name)
+	var found *ns2server
+	for _, srv := range config.Servers {
+		if name == "" && !srv.hasPlayerIDs() {
+			continue
+		}
+		if strings.HasPrefix(strings.ToLower(srv.Name), name) {
+			if found != nil {
+				return nil, fmt.Errorf("several servers match '%s', be more specific", name)
+			}
+			found = srv
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("server '%s' not found", name)
+	}
+	return found, nil
+}
+
+func playersList(args []string) (*discordgo.MessageSend, error) {
+	srv, err := findServer(strings.Join(args, " "))
+	if err != nil {
+		return nil, err
+	}
+	if !srv.hasPlayerIDs() {
+		return nil, fmt.Errorf("server %s doesn't provide player Steam IDs", srv.Name)
+	}
+	// the query loops replace these while the skills are being fetched, copy them once
+	srv.lock.RLock()
+	playerDetails, playerList := srv.playerDetails, srv.playerList
+	currentMap, avgSkill := srv.currentMap, srv.avgSkill
+	srv.lock.RUnlock()
+	details := map[string]*a2s.Player{}
+	for _, p := range playerDetails {
+		details[p.Name] = p
+	}
+	description := &strings.Builder{}
+	marineSum, alienSum, known := 0, 0, 0
+	for _, info := range playerList {
+		id := info.ID
+		name := info.Name
+		if !info.extended {
+			name, _ = playerNameAvatar(id)
+		}
+		line := fmt.Sprintf("**%s**", name)
+		if info.extended && info.Team != teamReadyRoom {
+			line += " [" + teamNames[info.Team]
+			if info.Commander {
+				line += ", comm"
+			}
+			line += "]"
+		}
+		if skills, err := getSkills(id); err == nil {
+			recordSkills(id, skills)
+			line += fmt.Sprintf(" — %d/%d", skills.Marine, skills.Alien)
+			marineSum += skills.Marine
+			alienSum += skills.Alien
+			known++
+		}
+		if info.extended {
+			line += fmt.Sprintf(", score %d, %s", info.Score, (time.Duration(info.Connected) * time.Second).String())
+		} else if p, ok := details[name]; ok {
+			line += fmt.Sprintf(", score %d, %s", p.Score, (time.Duration(p.Duration) * time.Second).String())
+		}
+		if description.Len()+len(line) > 4000 {
+			description.WriteString("...")
+			break
+		}
+		description.WriteString(line + "\n")
+	}
+	if len(playerList) == 0 {
+		description.WriteString("No players.")
+	}
+	msg := &discordgo.MessageSend{Embed: &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("%s [%s]", srv.Name, currentMap),
+		Description: description.String(),
+		Footer:      &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("Server skill: %d. Skills are shown as marine/alien", avgSkill)},
+	}}
+	if known > 0 {
+		msg.Embed.Fields = []*discordgo.MessageEmbedField{
+			{
+				Name:   "Marine average",
+				Value:  fmt.Sprint(marineSum / known),
+				Inline: true,
+			},
+			{
+				Name:   "Alien average",
+				Value:  fmt.Sprint(alienSum / known),
+				Inline: true,
+			},
+		}
+	}
+	return msg, nil
+}
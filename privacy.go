@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"go.etcd.io/bbolt"
+	"rkfg.me/ns2query/db"
+)
+
+// playerSettings returns the settings of the Discord user bound to the player, unbound players have the default ones
+func playerSettings(tx *bbolt.Tx, playerID uint32) db.UserSettings {
+	userID, err := db.NewSteamToDiscordBucket(tx).GetValue(playerID)
+	if err != nil {
+		return db.UserSettings{}
+	}
+	settings, _ := db.NewUserSettingsBucket(tx).GetValue(userID)
+	return settings
+}
+
+// filterTracked removes the players who disabled the history from the list
+func filterTracked(ids []uint32) (result []uint32) {
+	bdb.View(func(tx *bbolt.Tx) error {
+		for _, id := range ids {
+			if !playerSettings(tx, id).NoHistory {
+				result = append(result, id)
+			}
+		}
+		return nil
+	})
+	return
+}
+
+// untrackedJoins returns the players without history who weren't in the previous list. Their sessions aren't stored
+// so the players already on the server when the bot starts aren't reported.
+func (srv *ns2server) untrackedJoins(ids []uint32, tracked []uint32) (joined []uint32) {
+	present := map[uint32]struct{}{}
+	for _, id := range ids {
+		if slices.Contains(tracked, id) {
+			continue
+		}
+		present[id] = struct{}{}
+		if _, ok := srv.untrackedIDs[id]; !ok && srv.untrackedIDs != nil {
+			joined = append(joined, id)
+		}
+	}
+	srv.untrackedIDs = present
+	return
+}
+
+func onOff(enabled bool) string {
+	if enabled {
+		return "on"
+	}
+	return "off"
+}
+
+func privacy(args []string, author *discordgo.User) (*discordgo.MessageSend, error) {
+	if len(args) == 0 {
+		settings := getUserSettings(author.ID)
+		return &discordgo.MessageSend{Embed: &discordgo.MessageEmbed{
+			Title: "Privacy settings",
+			Fields: []*discordgo.MessageEmbedField{
+				{Name: "announce", Value: onOff(!settings.NoAnnounce), Inline: true},
+				{Name: "history", Value: onOff(!settings.NoHistory), Inline: true},
+				{Name: "leaderboard", Value: onOff(!settings.HideFromLeaderboard), Inline: true},
+				{Name: "follow", Value: onOff(!settings.HideFromFollow), Inline: true},
+			},
+			Footer: &discordgo.MessageEmbedFooter{Text: "Use -privacy announce|history on|off to change, -forgetme to remove all your data"},
+		}}, nil
+	}
+	if len(args) != 2 {
+		return nil, fmt.Errorf("usage: `-privacy announce|history on|off`")
+	}
+	value := strings.ToLower(args[1])
+	if value != "on" && value != "off" {
+		return nil, fmt.Errorf("usage: `-privacy announce|history on|off`")
+	}
+	off := value == "off"
+	var content string
+	err := updateUserSettings(author.ID, func(s *db.UserSettings) {
+		switch strings.ToLower(args[0]) {
+		case "announce":
+			s.NoAnnounce = off
+			content = "You will be announced when joining the servers."
+			if off {
+				content = "You won't be announced when joining or leaving the servers and listed among the regulars."
+			}
+		case "history":
+			s.NoHistory = off
+			content = "Your skill history and playtime will be recorded."
+			if off {
+				content = "Your skill history and playtime won't be recorded and you won't be shown on the leaderboards. " +
+					"Use `-forgetme` to remove the data recorded so far."
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if content == "" {
+		return nil, fmt.Errorf("unknown privacy setting '%s', use `announce` or `history`", args[0])
+	}
+	return &discordgo.MessageSend{Content: content}, nil
+}
+
+func forgetMe(s *discordgo.Session, args []string, author *discordgo.User) (*discordgo.MessageSend, error) {
+	if len(args) != 1 || strings.ToLower(args[0]) != "confirm" {
+		return &discordgo.MessageSend{Content: "This removes your binding, settings, follows, skill history, playtime and " +
+			"everything else the bot knows about you. It can't be undone. Use `-forgetme confirm` to proceed."}, nil
+	}
+	var steamID uint32
+	err := bdb.Update(func(tx *bbolt.Tx) (err error) {
+		if steamID, err = db.ForgetUser(tx, author.ID); err != nil || steamID == 0 {
+			return
+		}
+		return forgetSteamCache(tx, steamID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	forgetCard(steamID)
+	log.Printf("Forgot user %s bound to %d", author.ID, steamID)
+	go updateSkillRoles(s, author.ID, nil)
+	return &discordgo.MessageSend{Content: "All your data has been removed."}, nil
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.etcd.io/bbolt"
+	"rkfg.me/ns2query/db"
+)
+
+const (
+	readyEmoji = "\u2705"
+)
+
+type pugConfig struct {
+	Size         int           `json:"size"`
+	IdleTimeout  time.Duration `json:"idle_timeout"`
+	ReadyTimeout time.Duration `json:"ready_timeout"`
+	ChannelID    string        `json:"channel_id"`
+}
+
+type readyCheck struct {
+	messageID string
+	ready     map[string]bool
+	timer     *time.Timer
+}
+
+// pugState guards the queue bucket and the ready check, the handlers are called concurrently
+var pugState struct {
+	sync.Mutex
+	readyCheck *readyCheck
+}
+
+func pugChannelID() string {
+	if config.Pug.ChannelID != "" {
+		return config.Pug.ChannelID
+	}
+	return config.ChannelID
+}
+
+type pugQueueEntry struct {
+	userID string
+	db.PugEntry
+}
+
+func getPugQueue() (result []pugQueueEntry) {
+	bdb.View(func(tx *bbolt.Tx) error {
+		return db.NewPugQueueBucket(tx).ForEachValue(func(userID string, e db.PugEntry) error {
+			result = append(result, pugQueueEntry{userID: userID, PugEntry: e})
+			return nil
+		})
+	})
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Joined.Before(result[j].Joined)
+	})
+	return
+}
+
+func removeFromPugQueue(userIDs ...string) {
+	err := bdb.Update(func(tx *bbolt.Tx) error {
+		queue := db.NewPugQueueBucket(tx)
+		for _, id := range userIDs {
+			if err := queue.DeleteValue(id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error removing users %v from the PUG queue: %s", userIDs, err)
+	}
+}
+
+func mentions(userIDs []string) string {
+	result := []string{}
+	for _, id := range userIDs {
+		result = append(result, "<@"+id+">")
+	}
+	return strings.Join(result, " ")
+}
+
+func pugJoin(s *discordgo.Session, author *discordgo.User) (*discordgo.MessageSend, error) {
+	if _, err := getBind(author.ID); err != nil {
+		return nil, fmt.Errorf("bind your Steam ID with `-bind` first, it's needed to balance the teams")
+	}
+	pugState.Lock()
+	defer pugState.Unlock()
+	if pugState.readyCheck != nil {
+		return nil, fmt.Errorf("the queue is full and the ready check is in progress, try again later")
+	}
+	queueSize := 0
+	err := bdb.Update(func(tx *bbolt.Tx) error {
+		queue := db.NewPugQueueBucket(tx)
+		if _, err := queue.GetValue(author.ID); err == nil {
+			return fmt.Errorf("you're already in the queue")
+		}
+		now := time.Now()
+		if err := queue.PutValue(author.ID, db.PugEntry{Joined: now, LastActive: now}); err != nil {
+			return err
+		}
+		queueSize = queue.Count()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if queueSize >= config.Pug.Size {
+		if err := startReadyCheck(s); err != nil {
+			log.Printf("Error sending the ready check: %s", err)
+			removeFromPugQueue(author.ID)
+			return nil, fmt.Errorf("couldn't start the ready check, try joining again later")
+		}
+		return nil, nil
+	}
+	return &discordgo.MessageSend{Content: fmt.Sprintf("%s joined the PUG queue (%d/%d).", author.String(), queueSize, config.Pug.Size)}, nil
+}
+
+func pugLeave(author *discordgo.User) (*discordgo.MessageSend, error) {
+	pugState.Lock()
+	defer pugState.Unlock()
+	err := bdb.View(func(tx *bbolt.Tx) error {
+		_, err := db.NewPugQueueBucket(tx).GetValue(author.ID)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("you're not in the queue")
+	}
+	removeFromPugQueue(author.ID)
+	content := fmt.Sprintf("%s left the PUG queue (%d/%d).", author.String(), len(getPugQueue()), config.Pug.Size)
+	if pugState.readyCheck != nil {
+		if pugState.readyCheck.timer != nil {
+			pugState.readyCheck.timer.Stop()
+		}
+		pugState.readyCheck = nil
+		content += " The ready check is cancelled."
+	}
+	return &discordgo.MessageSend{Content: content}, nil
+}
+
+func pugStatus() *discordgo.MessageSend {
+	pugState.Lock()
+	defer pugState.Unlock()
+	queue := getPugQueue()
+	description := &strings.Builder{}
+	for i, e := range queue {
+		ready := ""
+		if pugState.readyCheck != nil && pugState.readyCheck.ready[e.userID] {
+			ready = " " + readyEmoji
+		}
+		fmt.Fprintf(description, "%d. <@%s>, joined <t:%d:R>%s\n", i+1, e.userID, e.Joined.Unix(), ready)
+	}
+	if len(queue) == 0 {
+		description.WriteString("The queue is empty, use `-pug join` to join.")
+	}
+	return &discordgo.MessageSend{Embed: &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("PUG queue (%d/%d)", len(queue), config.Pug.Size),
+		Description: description.String(),
+	}}
+}
+
+// startReadyCheck must be called with pugState locked, the lock is released while the message is being sent and the
+// pending ready check keeps the queue closed meanwhile
+func startReadyCheck(s *discordgo.Session) error {
+	rc := &readyCheck{ready: map[string]bool{}}
+	userIDs := []string{}
+	for _, e := range getPugQueue() {
+		userIDs = append(userIDs, e.userID)
+		rc.ready[e.userID] = false
+	}
+	pugState.readyCheck = rc
+	pugState.Unlock()
+	msg, err := s.ChannelMessageSendComplex(pugChannelID(), &discordgo.MessageSend{
+		Content: mentions(userIDs),
+		Embed: &discordgo.MessageEmbed{
+			Title: "The PUG queue is full!",
+			Description: fmt.Sprintf("React with %s within %s to confirm you're ready to play.",
+				readyEmoji, config.Pug.ReadyTimeout.String()),
+			Color: 0x00aaff,
+		},
+	})
+	pugState.Lock()
+	if pugState.readyCheck != rc {
+		// somebody left the queue while the message was being sent
+		return nil
+	}
+	if err != nil {
+		pugState.readyCheck = nil
+		return err
+	}
+	rc.messageID = msg.ID
+	rc.timer = time.AfterFunc(config.Pug.ReadyTimeout, func() { readyCheckTimeout(rc) })
+	sendChan <- message{channelID: pugChannelID(), reactionAdd: &reaction{messageID: msg.ID, emojiID: readyEmoji}}
+	return nil
+}
+
+func readyCheckTimeout(rc *readyCheck) {
+	pugState.Lock()
+	defer pugState.Unlock()
+	if pugState.readyCheck != rc {
+		return
+	}
+	pugState.readyCheck = nil
+	notReady := []string{}
+	for id, ready := range rc.ready {
+		if !ready {
+			notReady = append(notReady, id)
+		}
+	}
+	removeFromPugQueue(notReady...)
+	sendChan <- message{MessageSend: &discordgo.MessageSend{
+		Content: fmt.Sprintf("Ready check failed, removed from the queue: %s. The queue is now %d/%d.",
+			mentions(notReady), len(getPugQueue()), config.Pug.Size),
+	}, channelID: pugChannelID()}
+}
+
+func pugReactionAdd(m *discordgo.MessageReactionAdd) {
+	if m.Emoji.Name != readyEmoji {
+		return
+	}
+	pugState.Lock()
+	defer pugState.Unlock()
+	rc := pugState.readyCheck
+	if rc == nil || rc.messageID != m.MessageID {
+		return
+	}
+	if _, ok := rc.ready[m.UserID]; !ok {
+		return
+	}
+	rc.ready[m.UserID] = true
+	for _, ready := range rc.ready {
+		if !ready {
+			return
+		}
+	}
+	rc.timer.Stop()
+	pugState.readyCheck = nil
+	userIDs := []string{}
+	for id := range rc.ready {
+		userIDs = append(userIDs, id)
+	}
+	removeFromPugQueue(userIDs...)
+	go announcePug(userIDs)
+}
+
+// pugServer returns the first server that's up and has enough free player slots
+func pugServer(players int) *ns2server {
+	for _, srv := range config.Servers {
+		srv.lock.RLock()
+		fits := srv.failures <= config.FailureLimit && srv.PlayerSlots-len(srv.players) >= players
+		srv.lock.RUnlock()
+		if fits {
+			return srv
+		}
+	}
+	return nil
+}
+
+// connectAddress converts the query address to the game address that's used to connect
+func connectAddress(address string) string {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		return address
+	}
+	return net.JoinHostPort(host, strconv.Itoa(p-1))
+}
+
+func announcePug(userIDs []string) {
+	players := []*shufflePlayer{}
+	for _, id := range userIDs {
+		playerID, err := getBind(id)
+		if err != nil {
+			log.Printf("Error getting PUG player %s: %s", id, err)
+			continue
+		}
+		p := &shufflePlayer{id: playerID, name: "<@" + id + ">"}
+		if p.skills, err = getSkills(playerID); err != nil {
+			log.Printf("Error getting skills of PUG player %s: %s", id, err)
+		} else {
+			recordSkills(playerID, p.skills)
+		}
+		players = append(players, p)
+	}
+	marines, aliens := balanceTeams(players)
+	msg := &discordgo.MessageSend{
+		Content: mentions(userIDs),
+		Embed: &discordgo.MessageEmbed{
+			Title: "Everyone is ready, the PUG is on!",
+			Fields: []*discordgo.MessageEmbedField{
+				{
+					Name:   fmt.Sprintf("Marines (avg %d)", marines.average(true)),
+					Value:  formatTeam(marines),
+					Inline: true,
+				},
+				{
+					Name:   fmt.Sprintf("Aliens (avg %d)", aliens.average(false)),
+					Value:  formatTeam(aliens),
+					Inline: true,
+				},
+			},
+			Color: 0x00aaff,
+		},
+	}
+	if srv := pugServer(len(userIDs)); srv != nil {
+		msg.Embed.Description = fmt.Sprintf("Server: %s, connect to %s", srv.mapTitle(), connectAddress(srv.Address))
+	} else {
+		msg.Embed.Description = "None of the servers has enough free slots right now."
+	}
+	sendChan <- message{MessageSend: msg, channelID: pugChannelID()}
+}
+
+// pugTouch refreshes the activity time of a queued user, it's only written once a minute to not hit the database on
+// every message
+func pugTouch(userID string) {
+	var entry db.PugEntry
+	err := bdb.View(func(tx *bbolt.Tx) (err error) {
+		entry, err = db.NewPugQueueBucket(tx).GetValue(userID)
+		return
+	})
+	if err != nil || time.Since(entry.LastActive) < time.Minute {
+		return
+	}
+	pugState.Lock()
+	defer pugState.Unlock()
+	bdb.Update(func(tx *bbolt.Tx) error {
+		queue := db.NewPugQueueBucket(tx)
+		entry, err := queue.GetValue(userID)
+		if err != nil {
+			return nil
+		}
+		entry.LastActive = time.Now()
+		return queue.PutValue(userID, entry)
+	})
+}
+
+func expirePugQueue() {
+	pugState.Lock()
+	defer pugState.Unlock()
+	if pugState.readyCheck != nil {
+		return
+	}
+	expired := []string{}
+	for _, e := range getPugQueue() {
+		if time.Since(e.LastActive) > config.Pug.IdleTimeout {
+			expired = append(expired, e.userID)
+		}
+	}
+	if len(expired) == 0 {
+		return
+	}
+	removeFromPugQueue(expired...)
+	sendChan <- message{MessageSend: &discordgo.MessageSend{
+		Content: fmt.Sprintf("Removed from the PUG queue due to inactivity: %s. The queue is now %d/%d.",
+			mentions(expired), len(getPugQueue()), config.Pug.Size),
+	}, channelID: pugChannelID()}
+}
+
+func pugExpiryLoop(restartChan chan struct{}) {
+	for {
+		select {
+		case <-time.After(time.Minute):
+			expirePugQueue()
+		case <-restartChan:
+			log.Print("Restart request received, stopping PUG queue expiry")
+			return
+		}
+	}
+}
+
+func pug(s *discordgo.Session, args []string, author *discordgo.User) (*discordgo.MessageSend, error) {
+	if len(args) == 0 {
+		return pugStatus(), nil
+	}
+	switch strings.ToLower(args[0]) {
+	case "join":
+		return pugJoin(s, author)
+	case "leave":
+		return pugLeave(author)
+	case "status":
+		return pugStatus(), nil
+	}
+	return nil, fmt.Errorf("unknown `-pug` command, use `join`, `leave` or `status`")
+}
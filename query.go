@@ -1,7 +1,6 @@
 package main
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
@@ -19,9 +18,12 @@ import (
 
 const (
 	timeFormat = "2 Jan 2006 15:04:05 -0700"
+	dateFormat = "2 Jan 2006"
 )
 
 func (srv *ns2server) serverStatus() *discordgo.MessageSend {
+	srv.lock.RLock()
+	defer srv.lock.RUnlock()
 	specSlots := srv.SpecSlots
 	playerSlots := srv.PlayerSlots - len(srv.players)
 	freeSlots := playerSlots + srv.SpecSlots
@@ -64,6 +66,20 @@ func (srv *ns2server) serverStatus() *discordgo.MessageSend {
 	} else if playersCount >= srv.PlayerSlots {
 		msg.Embed.Color = 0xff3300
 	}
+	if marines, aliens, commanders, ok := srv.teamSummary(); ok {
+		msg.Embed.Fields = append(msg.Embed.Fields, &discordgo.MessageEmbedField{
+			Name:   "Marines vs aliens",
+			Value:  fmt.Sprintf("%d vs %d", marines, aliens),
+			Inline: true,
+		})
+		if len(commanders) > 0 {
+			msg.Embed.Fields = append(msg.Embed.Fields, &discordgo.MessageEmbedField{
+				Name:   "Commanders",
+				Value:  strings.Join(commanders, ", "),
+				Inline: true,
+			})
+		}
+	}
 	if len(srv.regularNames) > 0 {
 		msg.Embed.Fields = append(msg.Embed.Fields, &discordgo.MessageEmbedField{
 			Name:  "Regulars",
@@ -94,6 +110,12 @@ func (srv *ns2server) maybeNotify() {
 	} else {
 		newState = full
 	}
+	srv.lock.Lock()
+	// a seeding round starts when an empty server gets seeders and ends when it's almost full, draining after a game
+	// doesn't count as seeding
+	srv.seedingRound = newState == seedingstarted && (srv.playersState == empty || srv.seedingRound)
+	srv.playersState = newState
+	srv.lock.Unlock()
 	if newState > srv.serverState && newState <= srv.maxStateToMessage {
 		srv.lastStatePromotion = time.Now()
 		srv.serverState = newState
@@ -149,33 +171,42 @@ func (srv *ns2server) queryServer() error {
 		log.Printf("server info query: %s", err)
 		errorcount++
 	} else {
+		srv.lock.Lock()
 		srv.currentMap = info.Map
+		srv.lock.Unlock()
 	}
 	rules, err := client.QueryRules()
 	if err != nil {
 		log.Printf("rules query: %s", err)
 		errorcount++
 	} else {
-		srv.avgSkill = 0
+		skill := 0
 		avgSkillStr := rules.Rules["AverageSkill"]
 		if avgSkillStr != "nan" && avgSkillStr != "" {
 			avgSkill, err := strconv.ParseFloat(avgSkillStr, 32)
 			if err != nil {
 				log.Printf("parsing avg skill: %s", err)
 			} else {
-				srv.avgSkill = int(avgSkill)
+				skill = int(avgSkill)
 			}
 		}
+		srv.lock.Lock()
+		srv.avgSkill = skill
+		srv.lock.Unlock()
 	}
 	playersInfo, err := client.QueryPlayer()
 	if err != nil {
 		log.Printf("player query: %s", err)
 		errorcount++
 	} else {
-		srv.players = srv.players[:0]
+		players := []string{}
 		for _, p := range playersInfo.Players {
-			srv.players = append(srv.players, p.Name)
+			players = append(players, p.Name)
 		}
+		srv.lock.Lock()
+		srv.players = players
+		srv.playerDetails = playersInfo.Players
+		srv.lock.Unlock()
 	}
 	srv.maybeNotify()
 	if errorcount > 2 {
@@ -186,7 +217,11 @@ func (srv *ns2server) queryServer() error {
 
 func (srv *ns2server) serverLoop() {
 	for {
+		start := time.Now()
 		err := srv.queryServer()
+		srv.lock.Lock()
+		srv.queryDuration = time.Since(start)
+		srv.lock.Unlock()
 		if err != nil {
 			log.Printf("Error: %s", err)
 			if neterr, ok := errors.Unwrap(err).(*net.OpError); ok && neterr.Op == "write" {
@@ -194,18 +229,27 @@ func (srv *ns2server) serverLoop() {
 				close(srv.restartChan)
 				return
 			}
+			srv.lock.Lock()
 			srv.failures++
-			if srv.failures > config.FailureLimit && srv.downSince == nil {
+			wentDown := srv.failures > config.FailureLimit && srv.downSince == nil
+			if wentDown {
 				now := time.Now().In(time.UTC)
 				srv.downSince = &now
+			}
+			srv.lock.Unlock()
+			if wentDown {
 				sendChan <- message{MessageSend: &discordgo.MessageSend{Content: srv.formatDowntimeMsg(true)}}
 			}
 		} else {
 			if srv.failures > config.FailureLimit && srv.downSince != nil {
 				sendChan <- message{MessageSend: &discordgo.MessageSend{Content: srv.formatDowntimeMsg(false)}}
+				srv.lock.Lock()
 				srv.downSince = nil
+				srv.lock.Unlock()
 			}
+			srv.lock.Lock()
 			srv.failures = 0
+			srv.lock.Unlock()
 		}
 		select {
 		case <-time.After(config.QueryInterval):
@@ -224,11 +268,13 @@ func (srv *ns2server) checkRegulars(ids []uint32) {
 	}
 	bdb.View(func(t *bbolt.Tx) error {
 		steamBucket := db.NewSteamToDiscordBucket(t)
-		srv.regularNames = srv.regularNames[:0]
+		settingsBucket := db.NewUserSettingsBucket(t)
+		regularNames := []string{}
 		for _, id := range ids {
-			name, err := steamBucket.GetValue(id)
-			if err == nil {
-				srv.regularNames = append(srv.regularNames, name)
+			userID, err := steamBucket.GetValue(id)
+			if settings, _ := settingsBucket.GetValue(userID); err == nil && !settings.NoAnnounce {
+				name := getBindName(t, userID)
+				regularNames = append(regularNames, name)
 				if _, exists := srv.newRegulars[id]; !exists {
 					if srv.regularTimeouts[id] == nil {
 						log.Printf("Adding regular to announce %s", name)
@@ -240,19 +286,56 @@ func (srv *ns2server) checkRegulars(ids []uint32) {
 				}
 			}
 		}
+		srv.lock.Lock()
+		srv.regularNames = regularNames
+		srv.lock.Unlock()
 		return nil
 	})
 }
 
-func (srv *ns2server) getPlayerIDs() (result []uint32, err error) {
+func (srv *ns2server) recordLastSeen(ids []uint32) {
+	err := bdb.Update(func(tx *bbolt.Tx) error {
+		lastSeen := db.NewLastSeenBucket(tx)
+		now := time.Now()
+		for _, id := range ids {
+			if err := lastSeen.PutValue(id, db.LastSeen{Time: now, Server: srv.Name}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error recording last seen players on %s: %s", srv.Name, err)
+	}
+}
+
+func (srv *ns2server) getPlayers() ([]playerInfo, error) {
 	httpClient := http.Client{Timeout: time.Second * 3}
 	resp, err := httpClient.Get(srv.IDURL)
 	if err != nil {
 		return nil, fmt.Errorf("error querying %s: %w", srv.IDURL, err)
-	} else {
-		err = json.NewDecoder(resp.Body).Decode(&result)
 	}
-	return
+	defer resp.Body.Close()
+	return decodePlayers(resp.Body)
+}
+
+// currentPlayers polls id_url if it's set, otherwise the last pushed list is used
+func (srv *ns2server) currentPlayers() ([]playerInfo, error) {
+	if srv.IDURL != "" {
+		return srv.getPlayers()
+	}
+	return srv.playerList, nil
+}
+
+// mapTitle is the server name with the current map used in the embed titles
+func (srv *ns2server) mapTitle() string {
+	srv.lock.RLock()
+	defer srv.lock.RUnlock()
+	return fmt.Sprintf("%s [%s]", srv.Name, srv.currentMap)
+}
+
+func (srv *ns2server) hasPlayerIDs() bool {
+	return srv.IDURL != "" || srv.Push
 }
 
 func (srv *ns2server) announceRegulars() {
@@ -260,22 +343,25 @@ func (srv *ns2server) announceRegulars() {
 		srv.newRegulars = map[uint32]regular{}
 		srv.announceScheduled = false
 	}()
-	ids, err := srv.getPlayerIDs()
+	players, err := srv.currentPlayers()
 	if err != nil {
 		log.Printf("Error getting IDs: %s", err)
 		return
 	}
 	msg := ""
-	idmap := map[uint32]struct{}{} // players currently on the server
-	for _, id := range ids {
-		idmap[id] = struct{}{}
+	idmap := map[uint32]playerInfo{} // players currently on the server
+	for _, p := range players {
+		idmap[p.ID] = p
 	}
 	for id, r := range srv.newRegulars {
-		if _, ok := idmap[id]; ok { // only announce those who are playing
+		if p, ok := idmap[id]; ok { // only announce those who are playing
 			if msg != "" {
 				msg += ", "
 			}
 			msg += r.name
+			if p.extended && (p.Team == teamMarines || p.Team == teamAliens) {
+				msg += " (" + teamNames[p.Team] + ")"
+			}
 			newTimeout := time.Now().Add(srv.RegularTimeout)
 			srv.regularTimeouts[id] = &newTimeout
 		}
@@ -287,7 +373,7 @@ func (srv *ns2server) announceRegulars() {
 		}
 		sendChan <- message{MessageSend: &discordgo.MessageSend{
 			Embed: &discordgo.MessageEmbed{
-				Title:       fmt.Sprintf("%s [%s]", srv.Name, srv.currentMap),
+				Title:       srv.mapTitle(),
 				Footer:      &discordgo.MessageEmbedFooter{Text: "Recently joined"},
 				Description: msg,
 				Color:       0x00aaff,
@@ -296,27 +382,50 @@ func (srv *ns2server) announceRegulars() {
 	}
 }
 
+// idsLoop polls id_url and receives the pushed player lists, both are processed the same way in this goroutine
 func (srv *ns2server) idsLoop() {
 	var announceChan <-chan time.Time
+	var pollChan <-chan time.Time
 	srv.newRegulars = map[uint32]regular{}
-	for {
-		ids, err := srv.getPlayerIDs()
-		if err != nil {
-			log.Printf("Error decoding ids: %s", err)
+	update := func(players []playerInfo) {
+		ids := playerIDsOf(players)
+		srv.lock.Lock()
+		srv.playerList = players
+		srv.lock.Unlock()
+		srv.checkRegulars(ids)
+		tracked := filterTracked(ids)
+		departed, joined := srv.trackSessions(tracked)
+		srv.announceDepartures(departed)
+		joined = append(joined, srv.untrackedJoins(ids, tracked)...)
+		srv.checkWatchlist(joined)
+		srv.notifyFollowers(joined)
+		srv.recordLastSeen(tracked)
+		srv.creditSeeders(tracked)
+		if len(srv.newRegulars) == 0 {
+			// make sure this never fires too early if there are no queued regulars
+			announceChan = time.After(srv.QueryIDInterval * 5)
 		} else {
-			srv.checkRegulars(ids)
-			if len(srv.newRegulars) == 0 {
-				// make sure this never fires too early if there are no queued regulars
-				announceChan = time.After(srv.QueryIDInterval * 5)
-			} else {
-				if !srv.announceScheduled {
-					announceChan = time.After(srv.AnnounceDelay)
-					srv.announceScheduled = true
-				}
+			if !srv.announceScheduled {
+				announceChan = time.After(srv.AnnounceDelay)
+				srv.announceScheduled = true
 			}
 		}
+	}
+	if srv.IDURL != "" {
+		pollChan = time.After(0)
+	}
+	for {
 		select {
-		case <-time.After(srv.QueryIDInterval):
+		case <-pollChan:
+			players, err := srv.getPlayers()
+			if err != nil {
+				log.Printf("Error decoding ids: %s", err)
+			} else {
+				update(players)
+			}
+			pollChan = time.After(srv.QueryIDInterval)
+		case players := <-srv.pushChan:
+			update(players)
 		case <-announceChan:
 			srv.announceRegulars()
 		case <-srv.restartChan:
@@ -332,7 +441,8 @@ func (srv *ns2server) query() {
 	srv.maxStateToMessage = full
 	srv.lastStateAnnounced = empty
 	go srv.serverLoop()
-	if srv.IDURL != "" {
+	if srv.hasPlayerIDs() {
+		srv.pushChan = make(chan []playerInfo, 1)
 		go srv.idsLoop()
 	}
 }
@@ -122,3 +122,34 @@ func TestNotification(t *testing.T) {
 	srv.players = fillPlayers(5)
 	notif(t, srv, "Seeding started! Players on the server: 1, 2, 3, 4, 5") // seeding still works
 }
+
+func TestSeedingRound(t *testing.T) {
+	srv := &ns2server{
+		Name:              "Test",
+		maxStateToMessage: full,
+		PlayerSlots:       20,
+		SpecSlots:         6,
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-sendChan:
+			case <-done:
+				return
+			}
+		}
+	}()
+	for _, step := range []struct {
+		players int
+		seeding bool
+	}{{4, true}, {6, true}, {12, false}, {21, false}, {6, false}, {2, false}, {5, true}} {
+		srv.players = fillPlayers(step.players)
+		passTime(srv)
+		srv.maybeNotify()
+		if srv.seedingRound != step.seeding {
+			t.Errorf("expected seeding round %t with %d players", step.seeding, step.players)
+		}
+	}
+}
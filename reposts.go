@@ -70,6 +70,7 @@ func startReposts(urlChan <-chan msgUrls, sendChan chan<- message) {
 		for _, u := range mu.Urls {
 			if msgLink, ok := knownUrls[u]; ok {
 				log.Printf("Found a link repost of %s", u)
+				repostsCounter.inc("link")
 				msg += fmt.Sprintf(" %s", msgLink)
 				continue
 			}
@@ -98,7 +99,9 @@ func startReposts(urlChan <-chan msgUrls, sendChan chan<- message) {
 					matchFound = true
 				}
 			}
-			if !matchFound {
+			if matchFound {
+				repostsCounter.inc("image")
+			} else {
 				d.Add(formatMessageLink(mu.Message), h)
 				imageAdded = true
 			}
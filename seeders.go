@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.etcd.io/bbolt"
+	"rkfg.me/ns2query/db"
+)
+
+type seedersConfig struct {
+	GuildID string `json:"guild_id"`
+	RoleID  string `json:"role_id"`
+}
+
+// creditSeeders adds the time passed since the previous player list to everyone on the server while it's being
+// seeded from empty, the credit is capped so a stalled list doesn't count as hours of seeding
+func (srv *ns2server) creditSeeders(ids []uint32) {
+	now := time.Now()
+	elapsed := now.Sub(srv.lastSeedCredit)
+	srv.lastSeedCredit = now
+	srv.lock.RLock()
+	seedingRound := srv.seedingRound
+	srv.lock.RUnlock()
+	if !seedingRound || len(ids) == 0 {
+		return
+	}
+	elapsed = min(elapsed, srv.QueryIDInterval*2)
+	err := bdb.Update(func(tx *bbolt.Tx) error {
+		seeding := db.NewSeedingBucket(tx)
+		for _, id := range ids {
+			if err := seeding.AddSeeding(id, now, uint32(elapsed.Seconds())); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error crediting seeders on %s: %s", srv.Name, err)
+	}
+}
+
+type seederEntry struct {
+	playerID uint32
+	userID   string
+	seconds  uint32
+}
+
+// points are minutes spent seeding
+func (e seederEntry) points() uint32 {
+	return e.seconds / 60
+}
+
+// topSeeders returns the players who seeded the most since the specified time, the players hidden from the
+// leaderboards are skipped
+func topSeeders(since time.Time, size int) (result []seederEntry) {
+	bdb.View(func(tx *bbolt.Tx) error {
+		steamBucket := db.NewSteamToDiscordBucket(tx)
+		for id, seconds := range db.NewSeedingBucket(tx).Totals(since) {
+			if s := playerSettings(tx, id); s.HideFromLeaderboard || s.NoHistory || seconds < 60 {
+				continue
+			}
+			userID, _ := steamBucket.GetValue(id)
+			result = append(result, seederEntry{playerID: id, userID: userID, seconds: seconds})
+		}
+		return nil
+	})
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].seconds == result[j].seconds {
+			return result[i].playerID < result[j].playerID
+		}
+		return result[i].seconds > result[j].seconds
+	})
+	if len(result) > size {
+		result = result[:size]
+	}
+	return
+}
+
+func seeders(args []string) (*discordgo.MessageSend, error) {
+	var since time.Time
+	description := "All time"
+	if len(args) > 1 {
+		return nil, fmt.Errorf("usage: `-seeders [period]`")
+	}
+	if len(args) == 1 && strings.ToLower(args[0]) != "all" {
+		period, ok := parsePeriod(args[0])
+		if !ok {
+			return nil, fmt.Errorf("invalid period '%s', use something like `12h`, `7d` or `2w`", args[0])
+		}
+		since = time.Now().Add(-period)
+		description = "Since " + since.Format(dateFormat)
+	}
+	entries := topSeeders(since, defaultTopSize)
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("nobody has seeded our servers yet")
+	}
+	names := &strings.Builder{}
+	bdb.View(func(tx *bbolt.Tx) error {
+		for i, e := range entries {
+			name := ""
+			if e.userID != "" {
+				name = getBindName(tx, e.userID)
+			} else {
+				name, _ = playerNameAvatar(e.playerID)
+			}
+			fmt.Fprintf(names, "%d. %s — %d (%s)\n", i+1, name, e.points(), formatPlaytime(time.Duration(e.seconds)*time.Second))
+		}
+		return nil
+	})
+	return &discordgo.MessageSend{Embed: &discordgo.MessageEmbed{
+		Title:       "Top seeders",
+		Description: description + "\n\n" + names.String(),
+		Footer:      &discordgo.MessageEmbedFooter{Text: "1 point per minute spent on a server being seeded"},
+	}}, nil
+}
+
+// rotateSeederRole gives the role to the best bound seeder of the last week and removes it from everyone else
+func rotateSeederRole(s *discordgo.Session) {
+	guildID, roleID := config.Seeders.GuildID, config.Seeders.RoleID
+	winner := ""
+	for _, e := range topSeeders(time.Now().Add(-time.Hour*24*7), maxTopSize) {
+		if e.userID != "" {
+			winner = e.userID
+			break
+		}
+	}
+	hasRole := false
+	after := ""
+	for {
+		page, err := s.GuildMembers(guildID, after, 1000)
+		if err != nil {
+			log.Printf("Error getting members of guild %s: %s", guildID, err)
+			return
+		}
+		for _, m := range page {
+			if !slices.Contains(m.Roles, roleID) {
+				continue
+			}
+			if m.User.ID == winner {
+				hasRole = true
+				continue
+			}
+			log.Printf("Removing the top seeder role from user %s", m.User.ID)
+			if err := s.GuildMemberRoleRemove(guildID, m.User.ID, roleID); err != nil {
+				log.Printf("Error removing role %s from user %s: %s", roleID, m.User.ID, err)
+			}
+		}
+		if len(page) < 1000 {
+			break
+		}
+		after = page[len(page)-1].User.ID
+	}
+	if winner == "" || hasRole {
+		return
+	}
+	log.Printf("Adding the top seeder role to user %s", winner)
+	if err := s.GuildMemberRoleAdd(guildID, winner, roleID); err != nil {
+		log.Printf("Error adding role %s to user %s: %s", roleID, winner, err)
+	}
+}
+
+func seederRoleLoop(restartChan chan struct{}, s *discordgo.Session) {
+	if config.Seeders.GuildID == "" || config.Seeders.RoleID == "" {
+		return
+	}
+	for {
+		select {
+		case <-time.After(time.Until(nextWeek(time.Now()))):
+			rotateSeederRole(s)
+		case <-restartChan:
+			log.Print("Restart request received, stopping top seeder role rotation")
+			return
+		}
+	}
+}
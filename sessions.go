@@ -0,0 +1,281 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.etcd.io/bbolt"
+	"rkfg.me/ns2query/db"
+)
+
+const (
+	weeklyTopSize = 10
+)
+
+var periodRegex = regexp.MustCompile(`^(\d+)([hdw])$`)
+
+func formatPlaytime(d time.Duration) string {
+	d = d.Round(time.Minute)
+	if d < time.Hour {
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+	return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+}
+
+// parsePeriod accepts periods like 12h, 7d or 2w
+func parsePeriod(s string) (time.Duration, bool) {
+	m := periodRegex.FindStringSubmatch(strings.ToLower(s))
+	if m == nil {
+		return 0, false
+	}
+	n, err := strconv.Atoi(m[1])
+	if err != nil || n < 1 {
+		return 0, false
+	}
+	unit := map[string]time.Duration{"h": time.Hour, "d": time.Hour * 24, "w": time.Hour * 24 * 7}[m[2]]
+	return time.Duration(n) * unit, true
+}
+
+// trackSessions opens the sessions of the players who joined the server and closes the sessions of those who left
+// it, the session end is the time the player was last seen. It must be called before the last seen time is updated.
+// The open sessions are stored so the players who were already on the server before a restart aren't reported as
+// joined again.
+func (srv *ns2server) trackSessions(ids []uint32) (departed map[uint32]db.SessionRecord, joined []uint32) {
+	departed = map[uint32]db.SessionRecord{}
+	present := map[uint32]struct{}{}
+	for _, id := range ids {
+		present[id] = struct{}{}
+	}
+	now := time.Now()
+	err := bdb.Update(func(tx *bbolt.Tx) error {
+		open := db.NewOpenSessionsBucket(tx)
+		lastSeen := db.NewLastSeenBucket(tx)
+		left := []uint32{}
+		open.ForEachValue(func(id uint32, s db.OpenSession) error {
+			if _, ok := present[id]; !ok && s.Server == srv.Name {
+				left = append(left, id)
+			}
+			return nil
+		})
+		for _, id := range left {
+			end := now
+			if ls, err := lastSeen.GetValue(id); err == nil && ls.Server == srv.Name {
+				end = ls.Time
+			}
+			record, err := db.CloseSession(tx, id, end)
+			if err != nil {
+				return err
+			}
+			departed[id] = record
+		}
+		for _, id := range ids {
+			s, err := open.GetValue(id)
+			if err == nil {
+				if s.Server == srv.Name {
+					continue
+				}
+				// moved from another server
+				if _, err := db.CloseSession(tx, id, now); err != nil {
+					return err
+				}
+			}
+			if err := open.PutValue(id, db.OpenSession{Server: srv.Name, Start: now}); err != nil {
+				return err
+			}
+			joined = append(joined, id)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error tracking sessions on %s: %s", srv.Name, err)
+		return map[uint32]db.SessionRecord{}, nil
+	}
+	return
+}
+
+// announceDepartures posts how long the bound players have played before leaving
+func (srv *ns2server) announceDepartures(departed map[uint32]db.SessionRecord) {
+	if !srv.AnnounceDepartures || len(departed) == 0 {
+		return
+	}
+	lines := []string{}
+	bdb.View(func(tx *bbolt.Tx) error {
+		steamBucket := db.NewSteamToDiscordBucket(tx)
+		for id, s := range departed {
+			if userID, err := steamBucket.GetValue(id); err == nil && !playerSettings(tx, id).NoAnnounce {
+				lines = append(lines, fmt.Sprintf("%s left after %s", getBindName(tx, userID), formatPlaytime(s.Duration())))
+			}
+		}
+		return nil
+	})
+	if len(lines) == 0 {
+		return
+	}
+	sort.Strings(lines)
+	channelID := srv.RegularChannelID
+	if channelID == "" {
+		channelID = config.ChannelID
+	}
+	sendChan <- message{MessageSend: &discordgo.MessageSend{
+		Embed: &discordgo.MessageEmbed{
+			Title:       srv.mapTitle(),
+			Footer:      &discordgo.MessageEmbedFooter{Text: "Recently left"},
+			Description: strings.Join(lines, "\n"),
+			Color:       0x00aaff,
+		},
+	}, channelID: channelID}
+}
+
+// playerPlaytime returns the time the player has spent on each server since the specified time, the zero time means
+// all time. The current session is included.
+func playerPlaytime(playerID uint32, since time.Time) map[string]time.Duration {
+	result := map[string]time.Duration{}
+	now := time.Now()
+	bdb.View(func(tx *bbolt.Tx) error {
+		if since.IsZero() {
+			playtime, _ := db.NewPlaytimeBucket(tx).GetValue(playerID)
+			for server, seconds := range playtime {
+				result[server] += time.Duration(seconds) * time.Second
+			}
+		} else {
+			for _, s := range db.NewSessionsBucket(tx).Sessions(playerID, since) {
+				result[s.Server] += s.End.Sub(laterTime(s.Start, since))
+			}
+		}
+		if s, err := db.NewOpenSessionsBucket(tx).GetValue(playerID); err == nil {
+			result[s.Server] += now.Sub(laterTime(s.Start, since))
+		}
+		return nil
+	})
+	return result
+}
+
+func laterTime(a time.Time, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+type playtimeEntry struct {
+	playerID uint32
+	playtime time.Duration
+}
+
+// topPlaytime sums up the sessions of all players since the specified time
+func topPlaytime(since time.Time, size int) (result []playtimeEntry) {
+	totals := map[uint32]time.Duration{}
+	now := time.Now()
+	bdb.View(func(tx *bbolt.Tx) error {
+		db.NewSessionsBucket(tx).ForEachValue(func(k db.HistoryKey, s db.Session) error {
+			if s.End.After(since) && !playerSettings(tx, k.PlayerID).NoHistory {
+				totals[k.PlayerID] += s.End.Sub(laterTime(k.Time, since))
+			}
+			return nil
+		})
+		return db.NewOpenSessionsBucket(tx).ForEachValue(func(playerID uint32, s db.OpenSession) error {
+			totals[playerID] += now.Sub(laterTime(s.Start, since))
+			return nil
+		})
+	})
+	for id, playtime := range totals {
+		result = append(result, playtimeEntry{playerID: id, playtime: playtime})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].playtime > result[j].playtime
+	})
+	if len(result) > size {
+		result = result[:size]
+	}
+	return
+}
+
+func playtime(args []string, author *discordgo.User) (*discordgo.MessageSend, error) {
+	var since time.Time
+	description := "All time"
+	if len(args) > 0 {
+		if period, ok := parsePeriod(args[len(args)-1]); ok {
+			since = time.Now().Add(-period)
+			description = "Since " + since.Format(dateFormat)
+			args = args[:len(args)-1]
+		} else if strings.ToLower(args[len(args)-1]) == "all" {
+			args = args[:len(args)-1]
+		}
+	}
+	playerID, err := playerIDFromArgs(args, author)
+	if err != nil {
+		return nil, err
+	}
+	servers := playerPlaytime(playerID, since)
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("the player hasn't been seen on our servers")
+	}
+	names := []string{}
+	var total time.Duration
+	for name, d := range servers {
+		names = append(names, name)
+		total += d
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return servers[names[i]] > servers[names[j]]
+	})
+	fields := []*discordgo.MessageEmbedField{}
+	for _, name := range names {
+		fields = append(fields, &discordgo.MessageEmbedField{Name: name, Value: formatPlaytime(servers[name]), Inline: true})
+	}
+	playerName, avatarURL := playerNameAvatar(playerID)
+	return &discordgo.MessageSend{Embed: &discordgo.MessageEmbed{
+		Author:      &discordgo.MessageEmbedAuthor{Name: playerName, IconURL: avatarURL},
+		Description: description,
+		Fields:      fields,
+		Footer:      &discordgo.MessageEmbedFooter{Text: "Total: " + formatPlaytime(total)},
+	}}, nil
+}
+
+func postWeeklyTop() {
+	entries := topPlaytime(time.Now().Add(-time.Hour*24*7), weeklyTopSize)
+	if len(entries) == 0 {
+		return
+	}
+	description := &strings.Builder{}
+	for i, e := range entries {
+		name, _ := playerNameAvatar(e.playerID)
+		fmt.Fprintf(description, "%d. %s — %s\n", i+1, name, formatPlaytime(e.playtime))
+	}
+	sendChan <- message{MessageSend: &discordgo.MessageSend{Embed: &discordgo.MessageEmbed{
+		Title:       "Most active players this week",
+		Description: description.String(),
+		Color:       0x00aaff,
+	}}, channelID: config.PlaytimeChannelID}
+}
+
+// nextWeek returns the beginning of the next Monday
+func nextWeek(now time.Time) time.Time {
+	days := (8 - int(now.Weekday())) % 7
+	if days == 0 {
+		days = 7
+	}
+	y, m, d := now.Date()
+	return time.Date(y, m, d+days, 0, 0, 0, 0, now.Location())
+}
+
+func weeklyTopLoop(restartChan chan struct{}) {
+	if config.PlaytimeChannelID == "" {
+		return
+	}
+	for {
+		select {
+		case <-time.After(time.Until(nextWeek(time.Now()))):
+			postWeeklyTop()
+		case <-restartChan:
+			log.Print("Restart request received, stopping weekly playtime top")
+			return
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParsePeriod(t *testing.T) {
+	for s, expected := range map[string]time.Duration{
+		"12h": time.Hour * 12,
+		"7d":  time.Hour * 24 * 7,
+		"2W":  time.Hour * 24 * 14,
+	} {
+		if d, ok := parsePeriod(s); !ok || d != expected {
+			t.Errorf("expected %s for %s, got %s", expected, s, d)
+		}
+	}
+	for _, s := range []string{"", "0d", "d", "7", "7y", "all"} {
+		if _, ok := parsePeriod(s); ok {
+			t.Errorf("expected %s to be invalid", s)
+		}
+	}
+}
+
+func TestFormatPlaytime(t *testing.T) {
+	for d, expected := range map[time.Duration]string{
+		time.Second * 20:                               "0m",
+		time.Minute * 45:                               "45m",
+		time.Hour*2 + time.Minute*13:                   "2h 13m",
+		time.Hour*26 + time.Minute*59 + time.Second*40: "27h 0m",
+	} {
+		if s := formatPlaytime(d); s != expected {
+			t.Errorf("expected %s for %s, got %s", expected, d, s)
+		}
+	}
+}
+
+func TestNextWeek(t *testing.T) {
+	for _, day := range []int{12, 13, 18} { // Monday, Tuesday and Sunday
+		now := time.Date(2024, 8, day, 15, 4, 5, 0, time.UTC)
+		if next := nextWeek(now); !next.Equal(time.Date(2024, 8, 19, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("unexpected next week for %s: %s", now, next)
+		}
+	}
+}
+
+func TestUntrackedJoins(t *testing.T) {
+	srv := &ns2server{}
+	if joined := srv.untrackedJoins([]uint32{1, 2}, []uint32{1}); len(joined) != 0 {
+		t.Errorf("the players present on start shouldn't be reported, got %v", joined)
+	}
+	if joined := srv.untrackedJoins([]uint32{1, 2, 3, 4}, []uint32{1, 4}); !slices.Equal(joined, []uint32{3}) {
+		t.Errorf("expected only the new untracked player 3 to join, got %v", joined)
+	}
+	srv.untrackedJoins([]uint32{1}, []uint32{1})
+	if joined := srv.untrackedJoins([]uint32{2}, nil); !slices.Equal(joined, []uint32{2}) {
+		t.Errorf("expected player 2 to join again after leaving, got %v", joined)
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"go.etcd.io/bbolt"
+	"rkfg.me/ns2query/db"
+)
+
+const (
+	maxShufflePlayers = 24
+)
+
+type shufflePlayer struct {
+	id     uint32
+	name   string
+	skills db.Skills
+}
+
+type team struct {
+	commander *shufflePlayer
+	players   []*shufflePlayer
+}
+
+// skill of the team is the sum of the players' field skill and the commander's comm skill for the side
+func (t team) skill(marines bool) (result int) {
+	for _, p := range t.players {
+		if marines {
+			result += p.skills.Marine
+		} else {
+			result += p.skills.Alien
+		}
+	}
+	if t.commander != nil {
+		if marines {
+			result += t.commander.skills.MarineComm
+		} else {
+			result += t.commander.skills.AlienComm
+		}
+	}
+	return
+}
+
+func (t team) size() int {
+	if t.commander != nil {
+		return len(t.players) + 1
+	}
+	return len(t.players)
+}
+
+func (t team) average(marines bool) int {
+	if t.size() == 0 {
+		return 0
+	}
+	return t.skill(marines) / t.size()
+}
+
+// resolvePlayer accepts a Discord mention, a `!name` of a bound user or a Steam ID
+func resolvePlayer(arg string) (playerID uint32, name string, err error) {
+	if userID, err := parseUserID(arg); err == nil {
+		if playerID, err = getBind(userID); err != nil {
+			return 0, "", fmt.Errorf("%s: %w", arg, err)
+		}
+		bdb.View(func(tx *bbolt.Tx) error {
+			name = getBindName(tx, userID)
+			return nil
+		})
+		return playerID, name, nil
+	}
+	if strings.HasPrefix(arg, discordPrefix) {
+		if playerID, err = playerIDFromDiscordName(strings.TrimPrefix(strings.ToLower(arg), discordPrefix)); err != nil {
+			return
+		}
+	} else if playerID, err = playerIDFromSteamID(arg); err != nil {
+		return
+	}
+	name, _ = playerNameAvatar(playerID)
+	return
+}
+
+// pickCommanders chooses the best marine commander and then the best alien commander among the rest
+func pickCommanders(players []*shufflePlayer) (marineComm int, alienComm int) {
+	marineComm, alienComm = -1, -1
+	for i, p := range players {
+		if marineComm < 0 || p.skills.MarineComm > players[marineComm].skills.MarineComm {
+			marineComm = i
+		}
+	}
+	for i, p := range players {
+		if i != marineComm && (alienComm < 0 || p.skills.AlienComm > players[alienComm].skills.AlienComm) {
+			alienComm = i
+		}
+	}
+	return
+}
+
+// balanceTeams splits the players into two teams of (almost) equal size with the smallest skill difference, taking
+// the side-specific skills into account. Every split is tried for up to maxShufflePlayers players, larger lists are
+// split greedily.
+func balanceTeams(players []*shufflePlayer) (marines team, aliens team) {
+	if len(players) < 2 {
+		return team{players: players}, team{}
+	}
+	mc, ac := pickCommanders(players)
+	marines.commander, aliens.commander = players[mc], players[ac]
+	rest := []*shufflePlayer{}
+	for i, p := range players {
+		if i != mc && i != ac {
+			rest = append(rest, p)
+		}
+	}
+	commDiff := marines.commander.skills.MarineComm - aliens.commander.skills.AlienComm
+	var toMarines []bool
+	if len(players) > maxShufflePlayers {
+		toMarines = splitGreedy(rest, commDiff)
+	} else {
+		toMarines = splitExhaustive(rest, commDiff)
+	}
+	for i, p := range rest {
+		if toMarines[i] {
+			marines.players = append(marines.players, p)
+		} else {
+			aliens.players = append(aliens.players, p)
+		}
+	}
+	return
+}
+
+func splitExhaustive(rest []*shufflePlayer, commDiff int) []bool {
+	bestDiff := math.MaxInt
+	var bestMask uint32
+	for mask := uint32(0); mask < 1<<len(rest); mask++ {
+		if n := bits.OnesCount32(mask); n != len(rest)/2 && n != (len(rest)+1)/2 {
+			continue
+		}
+		diff := commDiff
+		for i, p := range rest {
+			if mask&(1<<i) != 0 {
+				diff += p.skills.Marine
+			} else {
+				diff -= p.skills.Alien
+			}
+		}
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff < bestDiff {
+			bestDiff = diff
+			bestMask = mask
+		}
+	}
+	result := make([]bool, len(rest))
+	for i := range rest {
+		result[i] = bestMask&(1<<i) != 0
+	}
+	return result
+}
+
+// splitGreedy puts the strongest remaining player on the weaker side until one of the teams is full
+func splitGreedy(rest []*shufflePlayer, commDiff int) []bool {
+	order := make([]int, len(rest))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := rest[order[i]].skills, rest[order[j]].skills
+		return a.Marine+a.Alien > b.Marine+b.Alien
+	})
+	result := make([]bool, len(rest))
+	maxSize := (len(rest) + 1) / 2
+	diff, marines, aliens := commDiff, 0, 0
+	for _, i := range order {
+		if aliens == maxSize || (marines < maxSize && diff < 0) {
+			result[i] = true
+			diff += rest[i].skills.Marine
+			marines++
+		} else {
+			diff -= rest[i].skills.Alien
+			aliens++
+		}
+	}
+	return result
+}
+
+func formatTeam(t team) string {
+	result := ""
+	if t.commander != nil {
+		result = t.commander.name + " (comm)\n"
+	}
+	for _, p := range t.players {
+		result += p.name + "\n"
+	}
+	if result == "" {
+		return "-"
+	}
+	return result
+}
+
+func shuffle(args []string) (*discordgo.MessageSend, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("specify at least two players to shuffle")
+	}
+	if len(args) > maxShufflePlayers {
+		return nil, fmt.Errorf("too many players, at most %d are supported", maxShufflePlayers)
+	}
+	players := []*shufflePlayer{}
+	seen := map[uint32]struct{}{}
+	for _, a := range args {
+		playerID, name, err := resolvePlayer(a)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := seen[playerID]; ok {
+			return nil, fmt.Errorf("player %s is specified more than once", name)
+		}
+		seen[playerID] = struct{}{}
+		skills, err := getSkills(playerID)
+		if err != nil {
+			return nil, fmt.Errorf("error getting skills of %s: %w", name, err)
+		}
+		recordSkills(playerID, skills)
+		players = append(players, &shufflePlayer{id: playerID, name: name, skills: skills})
+	}
+	marines, aliens := balanceTeams(players)
+	return &discordgo.MessageSend{Embed: &discordgo.MessageEmbed{
+		Title: "Shuffled teams",
+		Fields: []*discordgo.MessageEmbedField{
+			{
+				Name:   fmt.Sprintf("Marines (avg %d)", marines.average(true)),
+				Value:  formatTeam(marines),
+				Inline: true,
+			},
+			{
+				Name:   fmt.Sprintf("Aliens (avg %d)", aliens.average(false)),
+				Value:  formatTeam(aliens),
+				Inline: true,
+			},
+		},
+		Footer: &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("Skill difference: %d", marines.skill(true)-aliens.skill(false))},
+	}}, nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+
+	"rkfg.me/ns2query/db"
+)
+
+func TestBalanceTeams(t *testing.T) {
+	skills := []db.Skills{
+		{Marine: 3000, Alien: 2800, MarineComm: 500, AlienComm: 400},
+		{Marine: 2500, Alien: 2600, MarineComm: 2000, AlienComm: 1500},
+		{Marine: 1000, Alien: 1200, MarineComm: 100, AlienComm: 1800},
+		{Marine: 1500, Alien: 1400},
+		{Marine: 2000, Alien: 2100},
+		{Marine: 800, Alien: 900},
+		{Marine: 1200, Alien: 1000},
+	}
+	players := []*shufflePlayer{}
+	for i, s := range skills {
+		players = append(players, &shufflePlayer{id: uint32(i + 1), skills: s})
+	}
+	marines, aliens := balanceTeams(players)
+	if marines.commander.id != 2 || aliens.commander.id != 3 {
+		t.Errorf("expected commanders 2 and 3, got %d and %d", marines.commander.id, aliens.commander.id)
+	}
+	if marines.size()+aliens.size() != len(players) {
+		t.Errorf("players lost: %d + %d", marines.size(), aliens.size())
+	}
+	if d := marines.size() - aliens.size(); d < -1 || d > 1 {
+		t.Errorf("unbalanced team sizes %d and %d", marines.size(), aliens.size())
+	}
+	// players 1 and 7 with the marine commander make a perfect match for the rest
+	if diff := marines.skill(true) - aliens.skill(false); diff != 0 {
+		t.Errorf("expected zero skill difference, got %d", diff)
+	}
+}
+
+func TestBalanceTeamsLarge(t *testing.T) {
+	players := []*shufflePlayer{}
+	for i := range 40 {
+		players = append(players, &shufflePlayer{id: uint32(i + 1), skills: db.Skills{Marine: 500 + i*97%2000, Alien: 600 + i*53%1800}})
+	}
+	marines, aliens := balanceTeams(players)
+	if marines.size() != 20 || aliens.size() != 20 {
+		t.Errorf("unbalanced team sizes %d and %d", marines.size(), aliens.size())
+	}
+	if diff := marines.skill(true) - aliens.skill(false); diff < -2000 || diff > 2000 {
+		t.Errorf("skill difference %d is too big", diff)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.etcd.io/bbolt"
+	"rkfg.me/ns2query/db"
+)
+
+const (
+	skillDeltaPeriod   = time.Hour * 24 * 7
+	skillHistoryPeriod = time.Hour * 24 * 90
+)
+
+// recordSkills stores a new snapshot if the skills have changed since the last one
+func recordSkills(playerID uint32, skills db.Skills) {
+	err := bdb.Update(func(tx *bbolt.Tx) error {
+		if playerSettings(tx, playerID).NoHistory {
+			return nil
+		}
+		history := db.NewSkillHistoryBucket(tx)
+		if latest, err := history.Latest(playerID); err == nil && latest.Skills == skills {
+			return nil
+		}
+		return history.PutValue(db.HistoryKey{PlayerID: playerID, Time: time.Now()}, skills)
+	})
+	if err != nil {
+		log.Printf("Error recording skills of player %d: %s", playerID, err)
+	}
+}
+
+// skillBaseline returns the snapshot the current skills should be compared to, that's the one in effect a week ago
+// or the oldest one if the player hasn't been tracked for that long
+func skillBaseline(playerID uint32) (result *db.SkillSnapshot) {
+	bdb.View(func(tx *bbolt.Tx) error {
+		history := db.NewSkillHistoryBucket(tx).History(playerID, time.Now().Add(-skillDeltaPeriod))
+		if len(history) > 0 {
+			result = &history[0]
+		}
+		return nil
+	})
+	return
+}
+
+// boundPlayers returns the bound Steam IDs mapped to the Discord user IDs
+func boundPlayers() map[uint32]string {
+	result := map[uint32]string{}
+	bdb.View(func(tx *bbolt.Tx) error {
+		return db.NewSteamToDiscordBucket(tx).ForEachValue(func(playerID uint32, userID string) error {
+			result[playerID] = userID
+			return nil
+		})
+	})
+	return result
+}
+
+func refreshSkills(s *discordgo.Session) {
+	players := boundPlayers()
+	log.Printf("Refreshing skills of %d bound players", len(players))
+	for id, userID := range players {
+		skills, err := getSkills(id)
+		if err != nil {
+			log.Printf("Error getting skills of player %d: %s", id, err)
+			continue
+		}
+		recordSkills(id, skills)
+		updateSkillRoles(s, userID, &skills)
+	}
+}
+
+// skillRefreshLoop continues the schedule from the last refresh so frequent restarts don't cause request bursts
+func skillRefreshLoop(restartChan chan struct{}, s *discordgo.Session) {
+	for {
+		var last time.Time
+		bdb.View(func(tx *bbolt.Tx) error {
+			last = db.LastSkillRefresh(tx)
+			return nil
+		})
+		select {
+		case <-time.After(time.Until(last.Add(config.SkillRefreshInterval))):
+			refreshSkills(s)
+			pruneSteamCache()
+			err := bdb.Update(func(tx *bbolt.Tx) error {
+				return db.SetLastSkillRefresh(tx, time.Now())
+			})
+			if err != nil {
+				log.Printf("Error saving the skill refresh time: %s", err)
+			}
+		case <-restartChan:
+			log.Print("Restart request received, stopping skill refresher")
+			return
+		}
+	}
+}
+
+func skillHistory(playerID uint32) (*discordgo.MessageSend, error) {
+	skills, err := getSkills(playerID)
+	if err != nil {
+		return nil, err
+	}
+	recordSkills(playerID, skills)
+	var history []db.SkillSnapshot
+	from := time.Now().Add(-skillHistoryPeriod)
+	bdb.View(func(tx *bbolt.Tx) error {
+		history = db.NewSkillHistoryBucket(tx).History(playerID, from)
+		return nil
+	})
+	if len(history) < 2 {
+		return nil, fmt.Errorf("not enough skill history yet, the skill hasn't changed since it was first recorded")
+	}
+	if history[0].Time.After(from) {
+		from = history[0].Time
+	}
+	playerName, avatarURL := playerNameAvatar(playerID)
+	chart, err := renderSkillChart(history, from, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return &discordgo.MessageSend{
+		Embed: &discordgo.MessageEmbed{
+			Description: fmt.Sprintf("Skill history since %s", from.Format(dateFormat)),
+			Author:      &discordgo.MessageEmbedAuthor{Name: playerName, IconURL: avatarURL},
+			Image:       &discordgo.MessageEmbedImage{URL: "attachment://skill.png"},
+		},
+		Files: []*discordgo.File{{Name: "skill.png", ContentType: "image/png", Reader: chart}},
+	}, nil
+}
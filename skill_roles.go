@@ -0,0 +1,92 @@
+package main
+
+import (
+	"log"
+	"slices"
+
+	"github.com/bwmarrin/discordgo"
+	"rkfg.me/ns2query/db"
+)
+
+type skillTier struct {
+	Name   string `json:"name"`
+	Below  int    `json:"below"`
+	RoleID string `json:"role_id"`
+}
+
+type skillRolesConfig struct {
+	GuildID  string      `json:"guild_id"`
+	Category string      `json:"category"`
+	Tiers    []skillTier `json:"tiers"`
+}
+
+func skillRolesEnabled() bool {
+	return config.SkillRoles.GuildID != "" && len(config.SkillRoles.Tiers) > 0
+}
+
+// skillTierRole returns the role of the first tier the skill is below, the tier without the limit matches any skill.
+// Players without skill don't get any role.
+func skillTierRole(skills *db.Skills) string {
+	if skills == nil {
+		return ""
+	}
+	category, ok := topCategories[config.SkillRoles.Category]
+	if !ok {
+		category = topCategories["skill"]
+	}
+	skill := category.value(*skills)
+	if skill <= 0 {
+		return ""
+	}
+	for _, t := range config.SkillRoles.Tiers {
+		if t.Below == 0 || skill < t.Below {
+			return t.RoleID
+		}
+	}
+	return ""
+}
+
+// updateSkillRoles gives the member the role of their skill tier and removes the other tier roles, nil skills remove
+// all of them
+func updateSkillRoles(s *discordgo.Session, userID string, skills *db.Skills) {
+	if !skillRolesEnabled() {
+		return
+	}
+	guildID := config.SkillRoles.GuildID
+	member, err := s.State.Member(guildID, userID)
+	if err != nil {
+		if member, err = s.GuildMember(guildID, userID); err != nil {
+			log.Printf("Error getting guild member %s: %s", userID, err)
+			return
+		}
+	}
+	target := skillTierRole(skills)
+	for _, t := range config.SkillRoles.Tiers {
+		has := slices.Contains(member.Roles, t.RoleID)
+		if t.RoleID == target && !has {
+			log.Printf("Adding skill tier role %s to user %s", t.Name, userID)
+			if err := s.GuildMemberRoleAdd(guildID, userID, t.RoleID); err != nil {
+				log.Printf("Error adding role %s to user %s: %s", t.RoleID, userID, err)
+			}
+		}
+		if t.RoleID != target && has {
+			log.Printf("Removing skill tier role %s from user %s", t.Name, userID)
+			if err := s.GuildMemberRoleRemove(guildID, userID, t.RoleID); err != nil {
+				log.Printf("Error removing role %s from user %s: %s", t.RoleID, userID, err)
+			}
+		}
+	}
+}
+
+func updatePlayerSkillRoles(s *discordgo.Session, userID string, playerID uint32) {
+	if !skillRolesEnabled() {
+		return
+	}
+	skills, err := getSkills(playerID)
+	if err != nil {
+		log.Printf("Error getting skills of player %d: %s", playerID, err)
+		return
+	}
+	recordSkills(playerID, skills)
+	updateSkillRoles(s, userID, &skills)
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Philipp15b/go-steamapi"
+	"go.etcd.io/bbolt"
+	"rkfg.me/ns2query/db"
+)
+
+const (
+	ns2AppID = 4920
+)
+
+type steamAPIConfig struct {
+	URL               string        `json:"url"`
+	Timeout           time.Duration `json:"timeout"`
+	RequestsPerSecond int           `json:"requests_per_second"`
+	Retries           int           `json:"retries"`
+	VanityTTL         time.Duration `json:"vanity_ttl"`
+	SummaryTTL        time.Duration `json:"summary_ttl"`
+	StatsTTL          time.Duration `json:"stats_ttl"`
+	PlaytimeTTL       time.Duration `json:"playtime_ttl"`
+}
+
+type steamClient struct {
+	steamAPIConfig
+	key         string
+	httpClient  http.Client
+	interval    time.Duration
+	lock        sync.Mutex
+	lastRequest time.Time
+}
+
+type statusError struct {
+	method string
+	code   int
+}
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("Steam API method %s returned status %d", e.method, e.code)
+}
+
+func (e statusError) temporary() bool {
+	return e.code == http.StatusTooManyRequests || e.code >= 500
+}
+
+// networkError means no response was received from the API
+type networkError struct {
+	method string
+	err    error
+}
+
+func (e networkError) Error() string {
+	return fmt.Sprintf("error calling Steam API method %s: %s", e.method, e.err)
+}
+
+func (e networkError) Unwrap() error {
+	return e.err
+}
+
+// retryable errors are network failures, timeouts (including those while reading the response) and temporary
+// status codes, everything else fails the same way when retried
+func retryable(err error) bool {
+	var ne networkError
+	var se statusError
+	var te net.Error
+	return errors.As(err, &ne) || errors.As(err, &se) && se.temporary() || errors.As(err, &te) && te.Timeout()
+}
+
+var (
+	steamAPI *steamClient
+
+	errPlayerNotFound = errors.New("player not found")
+)
+
+func durationOrDefault(d time.Duration, def time.Duration) time.Duration {
+	if d < 1 {
+		return def
+	}
+	return d * time.Second
+}
+
+func newSteamClient(cfg steamAPIConfig, key string) *steamClient {
+	if cfg.URL == "" {
+		cfg.URL = "https://api.steampowered.com"
+	}
+	cfg.Timeout = durationOrDefault(cfg.Timeout, time.Second*5)
+	if cfg.RequestsPerSecond < 1 {
+		cfg.RequestsPerSecond = 5
+	}
+	if cfg.Retries < 0 {
+		cfg.Retries = 0
+	} else if cfg.Retries == 0 {
+		cfg.Retries = 2
+	}
+	cfg.VanityTTL = durationOrDefault(cfg.VanityTTL, time.Hour*24)
+	cfg.SummaryTTL = durationOrDefault(cfg.SummaryTTL, time.Hour)
+	cfg.StatsTTL = durationOrDefault(cfg.StatsTTL, time.Minute*10)
+	cfg.PlaytimeTTL = durationOrDefault(cfg.PlaytimeTTL, time.Hour)
+	return &steamClient{
+		steamAPIConfig: cfg,
+		key:            key,
+		httpClient:     http.Client{Timeout: cfg.Timeout},
+		interval:       time.Second / time.Duration(cfg.RequestsPerSecond),
+	}
+}
+
+func (c *steamClient) wait() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if d := time.Until(c.lastRequest.Add(c.interval)); d > 0 {
+		time.Sleep(d)
+	}
+	c.lastRequest = time.Now()
+}
+
+func (c *steamClient) doRequest(method string, params url.Values, result any) error {
+	params.Set("key", c.key)
+	u := c.URL + "/" + method + "/?" + params.Encode()
+	c.wait()
+	resp, err := c.httpClient.Get(u)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err // the URL contains the API key, don't let it leak into the logs and chat
+		}
+		return networkError{method: method, err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return statusError{method: method, code: resp.StatusCode}
+	}
+	if err = json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("error decoding Steam API method %s response: %w", method, err)
+	}
+	return nil
+}
+
+func (c *steamClient) request(method string, params url.Values, result any) (err error) {
+	for i := 0; i <= c.Retries; i++ {
+		if i > 0 {
+			log.Printf("Retrying Steam API request %s after error: %s", method, err)
+			time.Sleep(time.Second * time.Duration(i))
+		}
+		if err = c.doRequest(method, params, result); err == nil || !retryable(err) {
+			return
+		}
+	}
+	return
+}
+
+// cached returns the value stored in the cache bucket under key if it's not expired, otherwise fetches and stores it
+func cached[T any](key string, ttl time.Duration, fetch func() (T, error)) (result T, err error) {
+	if bdb == nil {
+		return fetch()
+	}
+	var entry db.CacheEntry
+	bdb.View(func(tx *bbolt.Tx) (err error) {
+		entry, err = db.NewSteamCacheBucket(tx).GetValue(key)
+		return
+	})
+	if time.Now().Before(entry.Expires) && json.Unmarshal(entry.Data, &result) == nil {
+		return
+	}
+	result, err = fetch()
+	if err != nil {
+		return
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return result, nil
+	}
+	err = bdb.Update(func(tx *bbolt.Tx) error {
+		return db.NewSteamCacheBucket(tx).PutValue(key, db.CacheEntry{Expires: time.Now().Add(ttl), Data: data})
+	})
+	if err != nil {
+		log.Printf("Error caching %s: %s", key, err)
+	}
+	return result, nil
+}
+
+// pruneSteamCache deletes the expired entries, they're only overwritten when the same data is requested again
+func pruneSteamCache() {
+	var pruned int
+	err := bdb.Update(func(tx *bbolt.Tx) (err error) {
+		pruned, err = db.NewSteamCacheBucket(tx).PruneExpired(time.Now())
+		return
+	})
+	if err != nil {
+		log.Printf("Error pruning the Steam cache: %s", err)
+		return
+	}
+	log.Printf("Pruned %d expired Steam cache entries", pruned)
+}
+
+// forgetSteamCache deletes the cached data of the player and the vanity names resolved to their Steam ID
+func forgetSteamCache(tx *bbolt.Tx, playerID uint32) error {
+	cache := db.NewSteamCacheBucket(tx)
+	keys := []string{fmt.Sprintf("summary:%d", playerID), fmt.Sprintf("stats:%d", playerID), fmt.Sprintf("playtime:%d", playerID)}
+	err := cache.ForEachValue(func(key string, entry db.CacheEntry) error {
+		var id uint32
+		if strings.HasPrefix(key, "vanity:") && json.Unmarshal(entry.Data, &id) == nil && id == playerID {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := cache.DeleteValue(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *steamClient) resolveVanity(name string) (uint32, error) {
+	return cached("vanity:"+name, c.VanityTTL, func() (uint32, error) {
+		var resp struct {
+			Response steamapi.ResolveVanityURLResponse
+		}
+		err := c.request("ISteamUser/ResolveVanityURL/v0001", url.Values{"vanityurl": {name}}, &resp)
+		if err != nil {
+			return 0, err
+		}
+		if resp.Response.Success != 1 {
+			return 0, fmt.Errorf("vanity name not found")
+		}
+		return uint32(resp.Response.SteamID - steamID64Base), nil
+	})
+}
+
+// playerSummary adds the country code that's missing from the library type
+type playerSummary struct {
+	steamapi.PlayerSummary
+	CountryCode string `json:"loccountrycode"`
+}
+
+func (c *steamClient) playerSummary(playerID uint32) (*playerSummary, error) {
+	return cached(fmt.Sprintf("summary:%d", playerID), c.SummaryTTL, func() (*playerSummary, error) {
+		var resp struct {
+			Response struct {
+				Players []playerSummary
+			}
+		}
+		err := c.request("ISteamUser/GetPlayerSummaries/v0002",
+			url.Values{"steamids": {strconv.FormatUint(uint64(playerID)+steamID64Base, 10)}}, &resp)
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Response.Players) == 0 {
+			return nil, fmt.Errorf("%w: %d", errPlayerNotFound, playerID)
+		}
+		return &resp.Response.Players[0], nil
+	})
+}
+
+// playerStats returns the NS2 stats of the player, if the player has none (or the profile is private) the result
+// is empty
+func (c *steamClient) playerStats(playerID uint32) (*hive, error) {
+	return cached(fmt.Sprintf("stats:%d", playerID), c.StatsTTL, func() (*hive, error) {
+		var resp hive
+		err := c.request("ISteamUserStats/GetUserStatsForGame/v2", url.Values{
+			"steamid": {strconv.FormatUint(uint64(playerID)+steamID64Base, 10)},
+			"appid":   {strconv.Itoa(ns2AppID)},
+		}, &resp)
+		var se statusError
+		if errors.As(err, &se) && (se.code == http.StatusBadRequest || se.code == http.StatusForbidden) {
+			return &hive{}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &resp, nil
+	})
+}
+
+// playtime returns the total time the player has spent in NS2, it's an error if the game details are private
+func (c *steamClient) playtime(playerID uint32) (time.Duration, error) {
+	return cached(fmt.Sprintf("playtime:%d", playerID), c.PlaytimeTTL, func() (time.Duration, error) {
+		var resp struct {
+			Response struct {
+				Games []struct {
+					AppID           int `json:"appid"`
+					PlaytimeForever int `json:"playtime_forever"`
+				}
+			}
+		}
+		err := c.request("IPlayerService/GetOwnedGames/v0001", url.Values{
+			"steamid":                   {strconv.FormatUint(uint64(playerID)+steamID64Base, 10)},
+			"include_played_free_games": {"1"},
+			"appids_filter[0]":          {strconv.Itoa(ns2AppID)},
+		}, &resp)
+		if err != nil {
+			return 0, err
+		}
+		for _, g := range resp.Response.Games {
+			if g.AppID == ns2AppID {
+				return time.Duration(g.PlaytimeForever) * time.Minute, nil
+			}
+		}
+		return 0, fmt.Errorf("game details of player %d are private", playerID)
+	})
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func testSteamClient(t *testing.T, handler http.HandlerFunc) *steamClient {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	c := newSteamClient(steamAPIConfig{URL: srv.URL, RequestsPerSecond: 1000, Retries: 1}, "testkey")
+	return c
+}
+
+func TestSteamClient(t *testing.T) {
+	requests := map[string]int{}
+	c := testSteamClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		if r.URL.Query().Get("key") != "testkey" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/ISteamUser/ResolveVanityURL/v0001/":
+			if r.URL.Query().Get("vanityurl") == "someplayer" {
+				fmt.Fprint(w, `{"response": {"steamid": "76561197960290419", "success": 1}}`)
+			} else {
+				fmt.Fprint(w, `{"response": {"success": 42, "message": "No match"}}`)
+			}
+		case "/ISteamUser/GetPlayerSummaries/v0002/":
+			fmt.Fprint(w, `{"response": {"players": [`)
+		case "/IPlayerService/GetOwnedGames/v0001/":
+			if r.URL.Query().Get("steamid") == "76561197960290419" {
+				fmt.Fprint(w, `{"response": {"game_count": 1, "games": [{"appid": 4920, "playtime_forever": 90}]}}`)
+			} else {
+				fmt.Fprint(w, `{"response": {}}`)
+			}
+		case "/ISteamUserStats/GetUserStatsForGame/v2/":
+			if r.URL.Query().Get("steamid") == "76561197960290419" {
+				fmt.Fprint(w, `{"playerstats": {"stats": [{"name": "skill", "value": 1500}]}}`)
+			} else {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+	if id, err := c.resolveVanity("someplayer"); err != nil || id != 24691 {
+		t.Errorf("expected player ID 24691, got %d (%v)", id, err)
+	}
+	if _, err := c.resolveVanity("nobody"); err == nil {
+		t.Errorf("expected an error resolving an unknown vanity name")
+	}
+	if _, err := c.playerSummary(24691); err == nil {
+		t.Errorf("expected a JSON decoding error")
+	}
+	if requests["/ISteamUser/GetPlayerSummaries/v0002/"] != 1 {
+		t.Errorf("a JSON decoding error shouldn't be retried, got %d requests", requests["/ISteamUser/GetPlayerSummaries/v0002/"])
+	}
+	stats, err := c.playerStats(24691)
+	if err != nil || len(stats.Playerstats.Stats) != 1 || stats.Playerstats.Stats[0].Value != 1500 {
+		t.Errorf("unexpected stats %+v (%v)", stats, err)
+	}
+	if stats, err = c.playerStats(1); err != nil || len(stats.Playerstats.Stats) != 0 {
+		t.Errorf("expected empty stats for a player without them, got %+v (%v)", stats, err)
+	}
+	if playtime, err := c.playtime(24691); err != nil || playtime != time.Minute*90 {
+		t.Errorf("expected 90 minutes of playtime, got %s (%v)", playtime, err)
+	}
+	if _, err := c.playtime(1); err == nil {
+		t.Errorf("expected an error for private game details")
+	}
+	err = c.request("Unknown/Method/v1", url.Values{}, &struct{}{})
+	if se, ok := err.(statusError); !ok || se.code != http.StatusInternalServerError {
+		t.Errorf("expected a status error, got %v", err)
+	}
+	if requests["/Unknown/Method/v1/"] != 2 {
+		t.Errorf("expected the request to be retried once, got %d requests", requests["/Unknown/Method/v1/"])
+	}
+	c.URL = "http://127.0.0.1:1"
+	if err = c.request("Unknown/Method/v1", url.Values{}, &struct{}{}); !retryable(err) {
+		t.Errorf("expected a retryable network error, got %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+	"go.etcd.io/bbolt"
+	"rkfg.me/ns2query/db"
+)
+
+// maxWatchReasonLength keeps the reason within the embed field limit of the alert
+const maxWatchReasonLength = 1024
+
+type watchConfig struct {
+	ChannelID string        `json:"channel_id"`
+	Cooldown  time.Duration `json:"cooldown"`
+}
+
+// watchAlerted keeps the last alert time for every watched player, the alerts are only repeated after the cooldown
+var watchAlerted struct {
+	sync.Mutex
+	times map[uint32]time.Time
+}
+
+func watchAlertAllowed(playerID uint32) bool {
+	watchAlerted.Lock()
+	defer watchAlerted.Unlock()
+	if watchAlerted.times == nil {
+		watchAlerted.times = map[uint32]time.Time{}
+	}
+	if time.Since(watchAlerted.times[playerID]) < config.Watch.Cooldown {
+		return false
+	}
+	watchAlerted.times[playerID] = time.Now()
+	return true
+}
+
+// checkWatchlist alerts the admins about the watched players who joined the server, all players are checked regardless
+// of their privacy settings
+func (srv *ns2server) checkWatchlist(joined []uint32) {
+	if config.Watch.ChannelID == "" {
+		return
+	}
+	found := map[uint32]db.WatchEntry{}
+	bdb.View(func(tx *bbolt.Tx) error {
+		watchlist := db.NewWatchlistBucket(tx)
+		for _, id := range joined {
+			if entry, err := watchlist.GetValue(id); err == nil {
+				found[id] = entry
+			}
+		}
+		return nil
+	})
+	for id, entry := range found {
+		if !watchAlertAllowed(id) {
+			continue
+		}
+		name, _ := playerNameAvatar(id)
+		log.Printf("Watched player %s (%d) is on %s", name, id, srv.Name)
+		sendChan <- message{MessageSend: &discordgo.MessageSend{Embed: &discordgo.MessageEmbed{
+			Title: "Watched player joined",
+			Fields: []*discordgo.MessageEmbedField{
+				{Name: "Player", Value: fmt.Sprintf("%s (%d)", name, id), Inline: true},
+				{Name: "Server", Value: srv.mapTitle(), Inline: true},
+				{Name: "Time", Value: fmt.Sprintf("<t:%d:f>", time.Now().Unix()), Inline: true},
+				{Name: "Reason", Value: entry.Reason},
+			},
+			Footer: &discordgo.MessageEmbedFooter{Text: "Added " + entry.Added.Format(dateFormat)},
+			Color:  0xff3300,
+		}}, channelID: config.Watch.ChannelID}
+	}
+}
+
+func watchList() *discordgo.MessageSend {
+	description := ""
+	full := false
+	bdb.View(func(tx *bbolt.Tx) error {
+		return db.NewWatchlistBucket(tx).ForEachValue(func(id uint32, entry db.WatchEntry) error {
+			if full {
+				return nil
+			}
+			line := fmt.Sprintf("%d — %s (added by <@%s> on %s)\n", id, entry.Reason, entry.AddedBy, entry.Added.Format(dateFormat))
+			if len(description)+len(line) > 4000 {
+				description += "..."
+				full = true
+				return nil
+			}
+			description += line
+			return nil
+		})
+	})
+	if description == "" {
+		return &discordgo.MessageSend{Content: "The watchlist is empty."}
+	}
+	return &discordgo.MessageSend{Embed: &discordgo.MessageEmbed{Title: "Watchlist", Description: description}}
+}
+
+func watch(args []string, author *discordgo.User) (*discordgo.MessageSend, error) {
+	if len(args) == 0 {
+		return watchList(), nil
+	}
+	switch strings.ToLower(args[0]) {
+	case "list":
+		return watchList(), nil
+	case "add":
+		if len(args) < 3 {
+			return nil, fmt.Errorf("usage: `-watch add <Steam ID> <reason>`")
+		}
+		playerID, err := playerIDFromSteamID(args[1])
+		if err != nil {
+			return nil, err
+		}
+		entry := db.WatchEntry{Reason: strings.Join(args[2:], " "), AddedBy: author.ID, Added: time.Now()}
+		if utf8.RuneCountInString(entry.Reason) > maxWatchReasonLength {
+			return nil, fmt.Errorf("the reason is too long, keep it under %d characters", maxWatchReasonLength)
+		}
+		err = bdb.Update(func(tx *bbolt.Tx) error {
+			return db.NewWatchlistBucket(tx).PutValue(playerID, entry)
+		})
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("User %s added %d to the watchlist: %s", author.ID, playerID, entry.Reason)
+		return &discordgo.MessageSend{Content: fmt.Sprintf("Steam ID %d is on the watchlist now.", playerID)}, nil
+	case "remove":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("usage: `-watch remove <Steam ID>`")
+		}
+		playerID, err := playerIDFromSteamID(args[1])
+		if err != nil {
+			return nil, err
+		}
+		err = bdb.Update(func(tx *bbolt.Tx) error {
+			watchlist := db.NewWatchlistBucket(tx)
+			if _, err := watchlist.GetValue(playerID); err != nil {
+				return fmt.Errorf("Steam ID %d isn't on the watchlist", playerID)
+			}
+			return watchlist.DeleteValue(playerID)
+		})
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("User %s removed %d from the watchlist", author.ID, playerID)
+		return &discordgo.MessageSend{Content: fmt.Sprintf("Steam ID %d is removed from the watchlist.", playerID)}, nil
+	}
+	return nil, fmt.Errorf("unknown `-watch` command, use `add`, `remove` or `list`")
+}